@@ -0,0 +1,123 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/limejuice-cc/limepacker/compression"
+	"github.com/limejuice-cc/limepacker/manifest"
+	"github.com/rs/zerolog/log"
+)
+
+// OpenArchiveStream decompresses a tar archive compressed with a known algorithm and reads its files into
+// results, it does not require the reader to be seekable so the archive can be received over a pipe
+func OpenArchiveStream(r io.Reader, a compression.Algorithm) (Results, error) {
+	d, err := compression.NewDecompressor(r, a)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+
+	results := newResults()
+	if err := readArchive(results, tar.NewReader(d)); err != nil {
+		results.Close()
+		return nil, err
+	}
+	return results, nil
+}
+
+func readArchive(results *baseResults, tr *tar.Reader) error {
+	extractor := &entryExtractor{source: "archive"}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := extractor.extract(results, tr, hdr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryExtractor reads tar entries into results for both build outputs and archive streams
+type entryExtractor struct {
+	stripComponents int
+	skipUnsafePaths bool
+	scratchDir      string
+	// source describes where the entries are read from in errors
+	source string
+}
+
+func isSafePath(name string) bool {
+	cleaned := path.Clean(strings.TrimPrefix(name, "/"))
+	return cleaned != ".." && !strings.HasPrefix(cleaned, "../")
+}
+
+// normalizeName cleans the name and strips the leading components returning false if nothing is left
+func (e *entryExtractor) normalizeName(name string) (string, bool) {
+	parts := strings.Split(path.Clean(strings.TrimPrefix(name, "/")), "/")
+	if len(parts) <= e.stripComponents {
+		return "", false
+	}
+	return path.Join(parts[e.stripComponents:]...), true
+}
+
+// extract reads the entry into results
+func (e *entryExtractor) extract(results *baseResults, tr *tar.Reader, hdr *tar.Header) error {
+	if !isSafePath(hdr.Name) {
+		if !e.skipUnsafePaths {
+			return fmt.Errorf("unsafe path %s in %s", hdr.Name, e.source)
+		}
+		log.Warn().Msgf("skipping unsafe path %s in %s", hdr.Name, e.source)
+		return nil
+	}
+	name, ok := e.normalizeName(hdr.Name)
+	if !ok || name == "." {
+		return nil
+	}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		results.directories = append(results.directories, newDirectory(name, hdr.Uname, hdr.Gname, hdr.Uid, hdr.Gid, hdr.FileInfo().Mode(), manifest.NotSpecified))
+		return nil
+	case tar.TypeSymlink:
+		results.files = append(results.files, newLink(name, hdr.Linkname, hdr.Uname, hdr.Gname, hdr.Uid, hdr.Gid, hdr.FileInfo().Mode(), manifest.NotSpecified))
+		return nil
+	case tar.TypeLink:
+		if !isSafePath(hdr.Linkname) {
+			return fmt.Errorf("unsafe hard link target %s in %s", hdr.Linkname, e.source)
+		}
+		target, ok := e.normalizeName(hdr.Linkname)
+		if !ok {
+			return fmt.Errorf("hard link %s has a target %s outside the %s", hdr.Name, hdr.Linkname, e.source)
+		}
+		results.files = append(results.files, newLink(name, target, hdr.Uname, hdr.Gname, hdr.Uid, hdr.Gid, hdr.FileInfo().Mode(), manifest.NotSpecified))
+		return nil
+	}
+	f, err := newFile(tr, e.scratchDir, name, hdr.Uname, hdr.Gname, hdr.Uid, hdr.Gid, hdr.FileInfo().Mode(), manifest.NotSpecified)
+	if err != nil {
+		return err
+	}
+	results.files = append(results.files, f)
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/limejuice-cc/limepacker/compression"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenArchiveStream(t *testing.T) {
+	results := newTestResults(
+		newTestFile(t, "bin/app", "app", 0755),
+		newTestFile(t, "etc/app.conf", "conf", 0644),
+	)
+
+	var buf bytes.Buffer
+	c, err := compression.NewCompressor(&buf, compression.Zstandard)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = io.Copy(c, results.ContextReader())
+	assert.NoError(t, err)
+	assert.NoError(t, c.Close())
+
+	// hide everything but Read so the archive cannot be seeked
+	stream := struct{ io.Reader }{&buf}
+	opened, err := OpenArchiveStream(stream, compression.Zstandard)
+	if assert.NoError(t, err) && assert.Len(t, opened.Files(), 2) {
+		for i, f := range results.Files() {
+			assert.Equal(t, f.Name(), opened.Files()[i].Name())
+			assert.Equal(t, f.Body(), opened.Files()[i].Body())
+			assert.Equal(t, f.Mode(), opened.Files()[i].Mode())
+			assert.Equal(t, f.User(), opened.Files()[i].User())
+		}
+	}
+
+	_, err = OpenArchiveStream(bytes.NewReader([]byte("not an archive")), compression.Zstandard)
+	assert.Error(t, err)
+}
+
+func TestOpenArchiveStreamNames(t *testing.T) {
+	compress := func(archive []byte) io.Reader {
+		var buf bytes.Buffer
+		c, err := compression.NewCompressor(&buf, compression.Zstandard)
+		if assert.NoError(t, err) {
+			_, err = c.Write(archive)
+			assert.NoError(t, err)
+			assert.NoError(t, c.Close())
+		}
+		return &buf
+	}
+
+	archive := newTestTar(
+		testTarEntry{name: "./"},
+		testTarEntry{name: "./bin/"},
+		testTarEntry{name: "./bin/app", body: "app"},
+		testTarEntry{name: "etc//app.conf", body: "conf"},
+		testTarEntry{name: "./bin/link", typeflag: tar.TypeSymlink, linkname: "app"},
+		testTarEntry{name: "/bin/hard", typeflag: tar.TypeLink, linkname: "./bin/app"},
+	)
+	opened, err := OpenArchiveStream(compress(archive), compression.Zstandard)
+	if assert.NoError(t, err) {
+		if assert.Len(t, opened.Directories(), 1) {
+			assert.Equal(t, "bin", opened.Directories()[0].Name())
+		}
+		if assert.Len(t, opened.Files(), 4) {
+			assert.Equal(t, "bin/app", opened.Files()[0].Name())
+			assert.Equal(t, "etc/app.conf", opened.Files()[1].Name())
+			assert.Equal(t, "bin/link", opened.Files()[2].Name())
+			assert.Equal(t, "bin/hard", opened.Files()[3].Name())
+			assert.Equal(t, "bin/app", opened.Files()[3].LinkTarget())
+		}
+	}
+
+	for _, e := range []testTarEntry{
+		{name: "../app", body: "app"},
+		{name: "bin/../../app", body: "app"},
+		{name: "../link", typeflag: tar.TypeSymlink, linkname: "app"},
+		{name: "bin/hard", typeflag: tar.TypeLink, linkname: "../app"},
+	} {
+		_, err := OpenArchiveStream(compress(newTestTar(e)), compression.Zstandard)
+		assert.Error(t, err, e.name)
+	}
+}
@@ -15,13 +15,19 @@
 package builder
 
 import (
+	"archive/tar"
+	"bytes"
+	"crypto"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"strings"
 
+	"github.com/limejuice-cc/limepacker/compression"
 	"github.com/limejuice-cc/limepacker/manifest"
+	"github.com/limejuice-cc/limepacker/pkg/ssl"
 )
 
 // File represents a built file
@@ -29,20 +35,33 @@ type File interface {
 	Name() string
 	User() string
 	Group() string
+	// UID returns the numeric owner, which is used when the owner has no user name
+	UID() int
+	// GID returns the numeric group, which is used when the group has no name
+	GID() int
+	// Body returns the whole body, files larger than StreamingThreshold are read into memory on each call
 	Body() []byte
+	// Reader returns a reader over the body so it can be streamed
+	Reader() io.Reader
 	Size() int
 	Mode() os.FileMode
 	Type() manifest.FileType
+	// LinkTarget returns the target of a symbolic or hard link or an empty string for other files.
+	// Symbolic links have os.ModeSymlink set in their mode.
+	LinkTarget() string
 	String() string
 }
 
 type baseFile struct {
-	name     string
-	user     string
-	group    string
-	body     []byte
-	mode     os.FileMode
-	fileType manifest.FileType
+	name       string
+	user       string
+	group      string
+	uid        int
+	gid        int
+	body       []byte
+	mode       os.FileMode
+	fileType   manifest.FileType
+	linkTarget string
 }
 
 func (f *baseFile) Name() string {
@@ -56,10 +75,22 @@ func (f *baseFile) Group() string {
 	return f.group
 }
 
+func (f *baseFile) UID() int {
+	return f.uid
+}
+
+func (f *baseFile) GID() int {
+	return f.gid
+}
+
 func (f *baseFile) Body() []byte {
 	return f.body
 }
 
+func (f *baseFile) Reader() io.Reader {
+	return bytes.NewReader(f.body)
+}
+
 func (f *baseFile) Size() int {
 	return len(f.body)
 }
@@ -72,42 +103,215 @@ func (f *baseFile) Type() manifest.FileType {
 	return f.fileType
 }
 
+func (f *baseFile) LinkTarget() string {
+	return f.linkTarget
+}
+
 func (f *baseFile) String() string {
 	return fmt.Sprintf("File: %s", f.name)
 }
 
-func newFile(r io.Reader, name, user, group string, mode os.FileMode, fileType manifest.FileType) (File, error) {
-	body, err := ioutil.ReadAll(r)
-	if err != nil {
+// bodyDigest returns the SHA-256 digest of a file's body, streaming it so large files are not read
+// into memory
+func bodyDigest(f File) ([]byte, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, f.Reader()); err != nil {
 		return nil, err
 	}
+	return h.Sum(nil), nil
+}
 
-	return &baseFile{
-		name:     name,
-		user:     user,
-		group:    group,
-		body:     body,
-		mode:     mode,
-		fileType: fileType,
-	}, nil
+// withMode returns a copy of the file sharing its body
+func (f *baseFile) withMode(mode os.FileMode) File {
+	out := *f
+	out.mode = mode
+	return &out
+}
+
+// PermissionPolicy specifies how file permissions are normalized
+type PermissionPolicy struct {
+	// ClampWorldWritable replaces world writable permissions with 0755 for executables and 0644 otherwise
+	ClampWorldWritable bool
+}
+
+func (p PermissionPolicy) apply(mode os.FileMode) os.FileMode {
+	mode &^= os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+	if p.ClampWorldWritable && mode&0002 != 0 {
+		perm := os.FileMode(0644)
+		if mode&0111 != 0 {
+			perm = 0755
+		}
+		mode = (mode &^ os.ModePerm) | perm
+	}
+	return mode
 }
 
 // Results represents the results of a build operation
 type Results interface {
 	Files() []File
+	// Directories returns the directory entries, including empty directories, so their ownership and
+	// permissions can be recreated. Their mode includes os.ModeDir.
+	Directories() []File
+	// NormalizePermissions returns a copy of the results with setuid, setgid and sticky bits removed
+	// and world writable permissions optionally clamped
+	NormalizePermissions(policy PermissionPolicy) Results
+	// Open returns a reader over the body of the named file so large files can be streamed
+	Open(name string) (io.ReadCloser, error)
+	// ContextReader returns a tar archive of the files which is written lazily as it is read
+	ContextReader() io.Reader
+	// WriteArchive writes a compressed tar archive of the files to w, streaming each file's body
+	WriteArchive(w io.Writer, a compression.Algorithm, opts ...compression.CompressorOption) error
+	// Sign returns detached signatures over the content of each file keyed by file name
+	Sign(k ssl.Key) (map[string][]byte, error)
+	// VerifySignatures verifies the detached signatures produced by Sign
+	VerifySignatures(pub crypto.PublicKey, signatures map[string][]byte) error
+	// Close removes the temporary files holding bodies larger than StreamingThreshold, which are shared
+	// with results returned by NormalizePermissions
+	Close() error
 }
 
 type baseResults struct {
-	files []File
+	files       []File
+	directories []File
 }
 
 func (r *baseResults) Files() []File {
 	return r.files
 }
 
+func (r *baseResults) Directories() []File {
+	return r.directories
+}
+
+func (r *baseResults) NormalizePermissions(policy PermissionPolicy) Results {
+	out := newResults()
+	for _, d := range r.directories {
+		out.directories = append(out.directories, normalizeFile(d, policy))
+	}
+	for _, f := range r.files {
+		out.files = append(out.files, normalizeFile(f, policy))
+	}
+	return out
+}
+
+func normalizeFile(f File, policy PermissionPolicy) File {
+	if c, ok := f.(interface{ withMode(os.FileMode) File }); ok {
+		return c.withMode(policy.apply(f.Mode()))
+	}
+	return &baseFile{
+		name:       f.Name(),
+		user:       f.User(),
+		group:      f.Group(),
+		uid:        f.UID(),
+		gid:        f.GID(),
+		body:       f.Body(),
+		mode:       policy.apply(f.Mode()),
+		fileType:   f.Type(),
+		linkTarget: f.LinkTarget(),
+	}
+}
+
+func (r *baseResults) Close() error {
+	var firstErr error
+	for _, f := range r.files {
+		c, ok := f.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (r *baseResults) Open(name string) (io.ReadCloser, error) {
+	for _, f := range r.files {
+		if f.Name() == name {
+			return ioutil.NopCloser(f.Reader()), nil
+		}
+	}
+	return nil, fmt.Errorf("file %s not found in results", name)
+}
+
+func tarHeader(f File) *tar.Header {
+	mode := int64(f.Mode().Perm())
+	if f.Mode()&os.ModeSetuid != 0 {
+		mode |= 04000
+	}
+	if f.Mode()&os.ModeSetgid != 0 {
+		mode |= 02000
+	}
+	if f.Mode()&os.ModeSticky != 0 {
+		mode |= 01000
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     f.Name(),
+		Mode:     mode,
+		Size:     int64(f.Size()),
+		Uname:    f.User(),
+		Gname:    f.Group(),
+		Uid:      f.UID(),
+		Gid:      f.GID(),
+	}
+	if f.Mode().IsDir() {
+		hdr.Typeflag = tar.TypeDir
+		hdr.Size = 0
+	}
+	if f.LinkTarget() != "" {
+		hdr.Typeflag = tar.TypeLink
+		if f.Mode()&os.ModeSymlink != 0 {
+			hdr.Typeflag = tar.TypeSymlink
+		}
+		hdr.Linkname = f.LinkTarget()
+		hdr.Size = 0
+	}
+	return hdr
+}
+
+func (r *baseResults) writeArchive(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	for _, d := range r.directories {
+		if err := tw.WriteHeader(tarHeader(d)); err != nil {
+			return err
+		}
+	}
+	for _, f := range r.files {
+		if err := tw.WriteHeader(tarHeader(f)); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, f.Reader()); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func (r *baseResults) WriteArchive(w io.Writer, a compression.Algorithm, opts ...compression.CompressorOption) error {
+	c, err := compression.NewCompressor(w, a, opts...)
+	if err != nil {
+		return err
+	}
+	if err := r.writeArchive(c); err != nil {
+		c.Close()
+		return err
+	}
+	return c.Close()
+}
+
+func (r *baseResults) ContextReader() io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(r.writeArchive(pw))
+	}()
+	return pr
+}
+
 func newResults() *baseResults {
 	return &baseResults{
-		files: []File{},
+		files:       []File{},
+		directories: []File{},
 	}
 }
 
@@ -129,6 +333,12 @@ type Build interface {
 	Run() (Results, error)
 }
 
+// LabeledBuild is a build which can return separate results for each labeled output
+type LabeledBuild interface {
+	Build
+	RunLabeled() (map[string]Results, error)
+}
+
 type baseBuilder struct {
 	architecture string
 	os           string
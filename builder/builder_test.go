@@ -0,0 +1,150 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/limejuice-cc/limepacker/compression"
+	"github.com/limejuice-cc/limepacker/manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestResults(files ...File) *baseResults {
+	results := newResults()
+	results.files = append(results.files, files...)
+	return results
+}
+
+func newTestFile(t *testing.T, name, body string, mode os.FileMode) File {
+	f, err := newFile(strings.NewReader(body), "", name, "root", "root", 0, 0, mode, manifest.NotSpecified)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return f
+}
+
+func TestNormalizePermissions(t *testing.T) {
+	results := newTestResults(
+		newTestFile(t, "bin/setuid", "binary", os.ModeSetuid|os.ModeSetgid|0755),
+		newTestFile(t, "tmp/sticky", "sticky", os.ModeSticky|0777),
+		newTestFile(t, "etc/writable.conf", "conf", 0666),
+		newTestFile(t, "etc/normal.conf", "conf", 0640),
+	)
+
+	normalized := results.NormalizePermissions(PermissionPolicy{})
+	if assert.Len(t, normalized.Files(), 4) {
+		assert.Equal(t, os.FileMode(0755), normalized.Files()[0].Mode())
+		assert.Equal(t, os.FileMode(0777), normalized.Files()[1].Mode())
+		assert.Equal(t, os.FileMode(0666), normalized.Files()[2].Mode())
+		assert.Equal(t, os.FileMode(0640), normalized.Files()[3].Mode())
+	}
+
+	clamped := results.NormalizePermissions(PermissionPolicy{ClampWorldWritable: true})
+	if assert.Len(t, clamped.Files(), 4) {
+		assert.Equal(t, os.FileMode(0755), clamped.Files()[0].Mode())
+		assert.Equal(t, os.FileMode(0755), clamped.Files()[1].Mode())
+		assert.Equal(t, os.FileMode(0644), clamped.Files()[2].Mode())
+		assert.Equal(t, os.FileMode(0640), clamped.Files()[3].Mode())
+		assert.Equal(t, "etc/writable.conf", clamped.Files()[2].Name())
+		assert.Equal(t, []byte("conf"), clamped.Files()[2].Body())
+	}
+
+	assert.Equal(t, os.ModeSetuid|os.ModeSetgid|0755, results.Files()[0].Mode())
+}
+
+func TestContextReader(t *testing.T) {
+	results := newTestResults(
+		newTestFile(t, "bin/app", "app", 0755),
+		newTestFile(t, "etc/app.conf", "conf", 0644),
+	)
+
+	tr := tar.NewReader(results.ContextReader())
+	for _, f := range results.Files() {
+		hdr, err := tr.Next()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, f.Name(), hdr.Name)
+		assert.Equal(t, f.Mode(), hdr.FileInfo().Mode())
+		assert.Equal(t, "root", hdr.Uname)
+		body, err := ioutil.ReadAll(tr)
+		if assert.NoError(t, err) {
+			assert.Equal(t, f.Body(), body)
+		}
+	}
+	_, err := tr.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestWriteArchive(t *testing.T) {
+	results := newTestResults(
+		newTestFile(t, "bin/app", strings.Repeat("app", 100000), 0755),
+		newTestFile(t, "etc/app.conf", "conf", 0644),
+		newTestFile(t, "etc/empty", "", 0644),
+	)
+
+	// the buffered path writes each body in a single call
+	var buffered bytes.Buffer
+	tw := tar.NewWriter(&buffered)
+	for _, f := range results.Files() {
+		assert.NoError(t, tw.WriteHeader(tarHeader(f)))
+		_, err := tw.Write(f.Body())
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+
+	var streamed bytes.Buffer
+	if assert.NoError(t, results.WriteArchive(&streamed, compression.None)) {
+		assert.Equal(t, buffered.Bytes(), streamed.Bytes())
+	}
+
+	var compressed bytes.Buffer
+	if assert.NoError(t, results.WriteArchive(&compressed, compression.Zstandard)) {
+		opened, err := OpenArchiveStream(&compressed, compression.Zstandard)
+		if assert.NoError(t, err) && assert.Len(t, opened.Files(), 3) {
+			for i, f := range results.Files() {
+				assert.Equal(t, f.Body(), opened.Files()[i].Body())
+			}
+		}
+	}
+}
+
+func BenchmarkWriteArchive(b *testing.B) {
+	for _, size := range []int{1 << 20, 16 << 20, 64 << 20} {
+		f, err := newFile(bytes.NewReader(make([]byte, size)), "", "data", "root", "root", 0, 0, 0644, manifest.NotSpecified)
+		if err != nil {
+			b.Fatal(err)
+		}
+		results := newTestResults(f)
+		b.Run(fmt.Sprintf("%dMiB", size>>20), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				if err := results.WriteArchive(ioutil.Discard, compression.None); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		results.Close()
+	}
+}
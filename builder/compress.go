@@ -0,0 +1,49 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"io"
+
+	"github.com/limejuice-cc/limepacker/compression"
+)
+
+type countingWriter struct {
+	w     io.Writer
+	count int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.count += int64(n)
+	return n, err
+}
+
+// CompressFile writes the body of f through a compressor to w returning the number of compressed bytes written
+func CompressFile(w io.Writer, f File, a compression.Algorithm, opts ...compression.CompressorOption) (int64, error) {
+	counter := &countingWriter{w: w}
+	c, err := compression.NewCompressor(counter, a, opts...)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(c, f.Reader()); err != nil {
+		c.Close()
+		return counter.count, err
+	}
+	if err := c.Close(); err != nil {
+		return counter.count, err
+	}
+	return counter.count, nil
+}
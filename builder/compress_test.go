@@ -0,0 +1,44 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/limejuice-cc/limepacker/compression"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressFile(t *testing.T) {
+	f := newTestFile(t, "etc/app.conf", strings.Repeat("key=value\n", 100), 0644)
+
+	var buf bytes.Buffer
+	n, err := CompressFile(&buf, f, compression.Zstandard, compression.WithCompressionLevel(compression.SpeedFastest))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Less(t, n, int64(f.Size()))
+
+	d, err := compression.NewDecompressor(&buf, compression.Zstandard)
+	if assert.NoError(t, err) {
+		body, err := ioutil.ReadAll(d)
+		assert.NoError(t, err)
+		assert.Equal(t, f.Body(), body)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DiffKind specifies how a file differs between two results
+type DiffKind int
+
+const (
+	diffKindNotSet DiffKind = iota
+	// OnlyInA the file is only present in the first results
+	OnlyInA
+	// OnlyInB the file is only present in the second results
+	OnlyInB
+	// Changed the file is present in both results but differs
+	Changed
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case OnlyInA:
+		return "only in a"
+	case OnlyInB:
+		return "only in b"
+	case Changed:
+		return "changed"
+	default:
+		log.Panic().Msgf("unknown diff kind %d", k)
+	}
+	return "" // Never reached
+}
+
+// FileDiff describes a difference in a file between two results
+type FileDiff struct {
+	Name    string
+	Kind    DiffKind
+	Changes []string // the attributes which differ for a changed file
+}
+
+func (d FileDiff) String() string {
+	if d.Kind == Changed {
+		return fmt.Sprintf("%s: %s %v", d.Name, d.Kind, d.Changes)
+	}
+	return fmt.Sprintf("%s: %s", d.Name, d.Kind)
+}
+
+func compareFiles(a, b File) []string {
+	changes := []string{}
+	digestA, errA := bodyDigest(a)
+	digestB, errB := bodyDigest(b)
+	if errA != nil || errB != nil {
+		log.Warn().Msgf("cannot read %s to compare checksums", a.Name())
+		changes = append(changes, "checksum")
+	} else if !bytes.Equal(digestA, digestB) {
+		changes = append(changes, "checksum")
+	}
+	if a.Mode() != b.Mode() {
+		changes = append(changes, "mode")
+	}
+	if a.LinkTarget() != b.LinkTarget() {
+		changes = append(changes, "link target")
+	}
+	if a.User() != b.User() || a.Group() != b.Group() || a.UID() != b.UID() || a.GID() != b.GID() {
+		changes = append(changes, "ownership")
+	}
+	return changes
+}
+
+// DiffResults reports the files which are only in a, only in b or differ between the two sorted by name
+func DiffResults(a, b Results) []FileDiff {
+	filesA := map[string]File{}
+	for _, f := range a.Files() {
+		filesA[f.Name()] = f
+	}
+	filesB := map[string]File{}
+	for _, f := range b.Files() {
+		filesB[f.Name()] = f
+	}
+
+	diffs := []FileDiff{}
+	for name, fa := range filesA {
+		fb, ok := filesB[name]
+		if !ok {
+			diffs = append(diffs, FileDiff{Name: name, Kind: OnlyInA})
+			continue
+		}
+		if changes := compareFiles(fa, fb); len(changes) > 0 {
+			diffs = append(diffs, FileDiff{Name: name, Kind: Changed, Changes: changes})
+		}
+	}
+	for name := range filesB {
+		if _, ok := filesA[name]; !ok {
+			diffs = append(diffs, FileDiff{Name: name, Kind: OnlyInB})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	return diffs
+}
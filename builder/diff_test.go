@@ -0,0 +1,56 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/limejuice-cc/limepacker/manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffResults(t *testing.T) {
+	owned, err := newFile(strings.NewReader("owned"), "", "etc/owned.conf", "app", "app", 1000, 1000, 0644, manifest.NotSpecified)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	a := newTestResults(
+		newTestFile(t, "bin/app", "app v1", 0755),
+		newTestFile(t, "etc/app.conf", "conf", 0644),
+		newTestFile(t, "etc/owned.conf", "owned", 0644),
+		newTestFile(t, "etc/same.conf", "same", 0644),
+		newTestFile(t, "usr/share/a-only", "a", 0644),
+	)
+	b := newTestResults(
+		newTestFile(t, "bin/app", "app v2", 0700),
+		newTestFile(t, "etc/app.conf", "conf", 0600),
+		owned,
+		newTestFile(t, "etc/same.conf", "same", 0644),
+		newTestFile(t, "usr/share/b-only", "b", 0644),
+	)
+
+	assert.Equal(t, []FileDiff{
+		{Name: "bin/app", Kind: Changed, Changes: []string{"checksum", "mode"}},
+		{Name: "etc/app.conf", Kind: Changed, Changes: []string{"mode"}},
+		{Name: "etc/owned.conf", Kind: Changed, Changes: []string{"ownership"}},
+		{Name: "usr/share/a-only", Kind: OnlyInA},
+		{Name: "usr/share/b-only", Kind: OnlyInB},
+	}, DiffResults(a, b))
+
+	assert.Empty(t, DiffResults(a, a))
+	assert.Panics(t, func() { _ = diffKindNotSet.String() })
+}
@@ -19,40 +19,87 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
-	"github.com/limejuice-cc/limepacker/manifest"
+	"github.com/docker/docker/pkg/fileutils"
+	"github.com/limejuice-cc/limepacker/pkg/utility/keyvalue"
 	specs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/rs/zerolog/log"
 )
 
+// dockerAPI is the subset of the docker client used by the docker builder
+type dockerAPI interface {
+	ImageBuild(ctx context.Context, buildContext io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error)
+	ImageRemove(ctx context.Context, imageID string, options types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error)
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *specs.Platform, containerName string) (container.ContainerCreateCreatedBody, error)
+	ContainerStart(ctx context.Context, containerID string, options types.ContainerStartOptions) error
+	ContainerStop(ctx context.Context, containerID string, timeout *time.Duration) error
+	ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error
+	CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, types.ContainerPathStat, error)
+}
+
+type dockerOutput struct {
+	label   string
+	path    string
+	archive io.Reader
+}
+
 type dockerBuildFile struct {
 	name string
 	body []byte
 }
 
+const (
+	// removeAttempts is how many times removing the built image is attempted
+	removeAttempts = 5
+	// defaultRemoveBackoff is the delay before the first retry, doubling with each attempt
+	defaultRemoveBackoff = 250 * time.Millisecond
+)
+
 type dockerBuilder struct {
 	baseBuilder
 
+	client dockerAPI
+
 	dockerFile   string
 	dockerIgnore string
 	extraFiles   []*dockerBuildFile
 
-	tags      []string
-	buildArgs map[string]*string
-	env       []string
+	tags       []string
+	contentTag string
+	buildArgs  map[string]*string
+	env        []string
+
+	workingDir string
+	user       string
+
+	validateDockerfile bool
 
 	outputDirectory string
-	output          []byte
+	extractPaths    []string
+	labeledOutputs  []*dockerOutput
+	outputs         []*dockerOutput
+	stripComponents int
+	skipUnsafePaths bool
+	allowEmpty      bool
+	lenient         bool
+	scratchDir      string
+	scratchFiles    []*os.File
 	imageID         string
+	removeBackoff   time.Duration
 }
 
 type dockerResponseLine struct {
@@ -152,30 +199,132 @@ func parseDockerResponse(in string) (*dockerResponse, error) {
 	return out, nil
 }
 
-func (b *dockerBuilder) createContext() (io.Reader, error) {
-	var buf bytes.Buffer
-	tw := tar.NewWriter(&buf)
-
-	if err := writeDockerFile(tw, "Dockerfile", []byte(b.dockerFile)); err != nil {
+func (b *dockerBuilder) dockerClient() (dockerAPI, error) {
+	if b.client != nil {
+		return b.client, nil
+	}
+	cli, err := client.NewClientWithOpts()
+	if err != nil {
 		return nil, err
 	}
+	b.client = cli
+	return cli, nil
+}
 
-	if b.dockerIgnore != "" {
-		if err := writeDockerFile(tw, ".dockerignore", []byte(b.dockerIgnore)); err != nil {
-			return nil, err
+func (b *dockerBuilder) ignorePatterns() []string {
+	patterns := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(b.dockerIgnore))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
 		}
+		patterns = append(patterns, line)
+	}
+	return patterns
+}
+
+func (b *dockerBuilder) contextFiles() ([]*dockerBuildFile, error) {
+	files := []*dockerBuildFile{{name: "Dockerfile", body: []byte(b.dockerFile)}}
+
+	if b.dockerIgnore != "" {
+		files = append(files, &dockerBuildFile{name: ".dockerignore", body: []byte(b.dockerIgnore)})
 	}
 
+	patterns := b.ignorePatterns()
 	for _, f := range b.extraFiles {
-		if err := writeDockerFile(tw, f.name, f.body); err != nil {
+		if len(patterns) > 0 {
+			ignored, err := fileutils.Matches(f.name, patterns)
+			if err != nil {
+				return nil, err
+			}
+			if ignored {
+				continue
+			}
+		}
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+// ContextSize returns the total size in bytes and the number of files in the build context
+func (b *dockerBuilder) ContextSize() (int64, int, error) {
+	files, err := b.contextFiles()
+	if err != nil {
+		return 0, 0, err
+	}
+	var size int64
+	for _, f := range files {
+		size += int64(len(f.body))
+	}
+	return size, len(files), nil
+}
+
+// ContentHash returns a hex encoded sha256 digest of the build context files and build args
+func (b *dockerBuilder) ContentHash() (string, error) {
+	files, err := b.contextFiles()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for _, f := range files {
+		fmt.Fprintf(h, "file %s %d\n", f.name, len(f.body))
+		h.Write(f.body)
+	}
+
+	names := make([]string, 0, len(b.buildArgs))
+	for name := range b.buildArgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if value := b.buildArgs[name]; value != nil {
+			fmt.Fprintf(h, "arg %s=%s\n", name, *value)
+		} else {
+			fmt.Fprintf(h, "arg %s\n", name)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (b *dockerBuilder) imageTags() ([]string, error) {
+	tags := append([]string{}, b.tags...)
+	if b.contentTag != "" {
+		hash, err := b.ContentHash()
+		if err != nil {
 			return nil, err
 		}
+		tags = append(tags, fmt.Sprintf("%s:%s", b.contentTag, hash))
+	}
+	return tags, nil
+}
+
+func (b *dockerBuilder) createContext() (io.Reader, error) {
+	files, err := b.contextFiles()
+	if err != nil {
+		return nil, err
 	}
 
-	if err := tw.Close(); err != nil {
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		for _, f := range files {
+			if err := writeDockerFile(tw, f.name, f.body); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(tw.Close())
+	}()
+	buildContext, err := b.buffer("context-*.tar", pr)
+	if err != nil {
+		pr.CloseWithError(err)
 		return nil, err
 	}
-	return bytes.NewReader(buf.Bytes()), nil
+	return buildContext, nil
 }
 
 func writeDockerFile(tw *tar.Writer, name string, body []byte) error {
@@ -194,10 +343,14 @@ func (b *dockerBuilder) createBuildOptions() (*types.ImageBuildOptions, error) {
 	if err != nil {
 		return nil, err
 	}
+	tags, err := b.imageTags()
+	if err != nil {
+		return nil, err
+	}
 	return &types.ImageBuildOptions{
 		Context:    ctx,
 		Dockerfile: "Dockerfile",
-		Tags:       b.tags,
+		Tags:       tags,
 		BuildArgs:  b.buildArgs,
 
 		Remove: true,
@@ -209,7 +362,7 @@ func (b *dockerBuilder) build() (*dockerResponse, error) {
 	if err != nil {
 		return nil, err
 	}
-	cli, err := client.NewClientWithOpts()
+	cli, err := b.dockerClient()
 	if err != nil {
 		return nil, err
 	}
@@ -224,8 +377,11 @@ func (b *dockerBuilder) build() (*dockerResponse, error) {
 		return nil, err
 	}
 	resp, err := parseDockerResponse(sb.String())
+	if err != nil {
+		return nil, err
+	}
 	b.imageID = resp.ImageID().Hash()
-	return resp, err
+	return resp, nil
 }
 
 func (b *dockerBuilder) platform() *specs.Platform {
@@ -236,16 +392,18 @@ func (b *dockerBuilder) platform() *specs.Platform {
 	}
 }
 
-func (b *dockerBuilder) exec() error {
-	cli, err := client.NewClientWithOpts()
+func (b *dockerBuilder) exec() (err error) {
+	cli, err := b.dockerClient()
 	if err != nil {
 		return err
 	}
 
 	ctx := context.Background()
 	config := &container.Config{
-		Image: b.imageID,
-		Env:   b.env,
+		Image:      b.imageID,
+		Env:        b.env,
+		WorkingDir: b.workingDir,
+		User:       b.user,
 	}
 	hostConfig := &container.HostConfig{}
 	networkingConfig := &network.NetworkingConfig{}
@@ -256,6 +414,11 @@ func (b *dockerBuilder) exec() error {
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if removeErr := b.removeContainer(ctx, cli, createResponse.ID); err == nil {
+			err = removeErr
+		}
+	}()
 
 	options := types.ContainerStartOptions{}
 
@@ -263,74 +426,177 @@ func (b *dockerBuilder) exec() error {
 		return err
 	}
 
-	r, _, err := cli.CopyFromContainer(ctx, createResponse.ID, b.outputDirectory)
-	if err != nil {
-		return err
-	}
-	defer r.Close()
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, r); err != nil {
-		return err
+	b.outputs = []*dockerOutput{}
+	for _, output := range b.outputPaths() {
+		archive, err := b.copyFromContainer(ctx, cli, createResponse.ID, output.path)
+		if err != nil {
+			if !client.IsErrNotFound(err) {
+				return err
+			}
+			if !b.allowEmpty {
+				return fmt.Errorf("output directory %s not found in container or empty", output.path)
+			}
+			log.Warn().Msgf("output directory %s not found in container", output.path)
+			archive = bytes.NewReader(nil)
+		}
+		b.outputs = append(b.outputs, &dockerOutput{label: output.label, path: output.path, archive: archive})
 	}
 
-	b.output = buf.Bytes()
+	return nil
+}
 
-	if err := cli.ContainerStop(ctx, createResponse.ID, nil); err != nil {
+// removeContainer stops and removes the build container, removal is forced so it is attempted even when
+// stopping fails
+func (b *dockerBuilder) removeContainer(ctx context.Context, cli dockerAPI, containerID string) error {
+	stopErr := cli.ContainerStop(ctx, containerID, nil)
+	removeOptions := types.ContainerRemoveOptions{Force: true, RemoveVolumes: true}
+	if err := cli.ContainerRemove(ctx, containerID, removeOptions); err != nil {
 		return err
 	}
+	return stopErr
+}
 
-	removeOptions := types.ContainerRemoveOptions{Force: true, RemoveVolumes: true}
-	if err := cli.ContainerRemove(ctx, createResponse.ID, removeOptions); err != nil {
-		return err
+func (b *dockerBuilder) outputPaths() []*dockerOutput {
+	out := []*dockerOutput{}
+	if len(b.extractPaths) > 0 {
+		for _, path := range b.extractPaths {
+			out = append(out, &dockerOutput{path: path})
+		}
+	} else if b.outputDirectory != "" {
+		out = append(out, &dockerOutput{path: b.outputDirectory})
 	}
+	return append(out, b.labeledOutputs...)
+}
 
-	return nil
+func (b *dockerBuilder) copyFromContainer(ctx context.Context, cli dockerAPI, containerID, path string) (io.Reader, error) {
+	r, _, err := cli.CopyFromContainer(ctx, containerID, path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return b.buffer("output-*.tar", r)
 }
 
 func (b *dockerBuilder) remove() error {
-	cli, err := client.NewClientWithOpts()
+	cli, err := b.dockerClient()
 	if err != nil {
 		return err
 	}
+	if b.imageID == "" {
+		return nil
+	}
 	ctx := context.Background()
+	// the image is removed by its id rather than a tag so untagged images are removed as well
 	options := types.ImageRemoveOptions{
 		Force:         true,
 		PruneChildren: true,
 	}
-	if _, err := cli.ImageRemove(ctx, b.imageID, options); err != nil {
-		return err
+	backoff := b.removeBackoff
+	for attempt := 1; ; attempt++ {
+		_, err := cli.ImageRemove(ctx, b.imageID, options)
+		if err == nil || client.IsErrNotFound(err) {
+			// an image which is already gone has been removed
+			return nil
+		}
+		if attempt == removeAttempts {
+			return fmt.Errorf("cannot remove image %s after %d attempts: %w", b.imageID, attempt, err)
+		}
+		log.Warn().Msgf("cannot remove image %s, retrying in %s: %v", b.imageID, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
 	}
-	return nil
 }
 
 func (b *dockerBuilder) extractResults() (Results, error) {
-	r := bytes.NewReader(b.output)
-	tr := tar.NewReader(r)
-
 	results := newResults()
+	skipped := extractionErrors{}
+
+	for _, output := range b.outputs {
+		if err := b.extractOutput(results, output); err != nil {
+			if !skipped.add(err) {
+				results.Close()
+				return nil, err
+			}
+		}
+	}
+
+	if len(skipped) > 0 {
+		return results, skipped
+	}
+	return results, nil
+}
+
+func (b *dockerBuilder) extractLabeledResults() (map[string]Results, error) {
+	labeled := map[string]*baseResults{}
+	skipped := extractionErrors{}
+
+	for _, output := range b.outputs {
+		results, ok := labeled[output.label]
+		if !ok {
+			results = newResults()
+			labeled[output.label] = results
+		}
+		if err := b.extractOutput(results, output); err != nil {
+			if !skipped.add(err) {
+				for _, results := range labeled {
+					results.Close()
+				}
+				return nil, err
+			}
+		}
+	}
+
+	out := make(map[string]Results, len(labeled))
+	for label, results := range labeled {
+		out[label] = results
+	}
+	if len(skipped) > 0 {
+		return out, skipped
+	}
+	return out, nil
+}
 
+func (b *dockerBuilder) extractOutput(results *baseResults, output *dockerOutput) error {
+	extracted := len(results.files)
+	err := b.extractArchive(results, output.archive)
+	if _, lenient := err.(extractionErrors); err != nil && !lenient {
+		return err
+	}
+	if len(results.files) == extracted && !b.allowEmpty {
+		return fmt.Errorf("output directory %s not found in container or empty", output.path)
+	}
+	return err
+}
+
+// extractor returns the entryExtractor reading the build output into results
+func (b *dockerBuilder) extractor() *entryExtractor {
+	return &entryExtractor{stripComponents: b.stripComponents, skipUnsafePaths: b.skipUnsafePaths, scratchDir: b.scratchDir, source: "build output"}
+}
+
+func (b *dockerBuilder) extractArchive(results *baseResults, archive io.Reader) error {
+	if b.lenient {
+		return b.extractArchiveLenient(results, archive)
+	}
+
+	tr := tar.NewReader(archive)
+	extractor := b.extractor()
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
 			break // End of archive
 		}
 		if err != nil {
-			return nil, err
+			return err
 		}
-		if hdr.FileInfo().IsDir() {
-			continue
+		if err := extractor.extract(results, tr, hdr); err != nil {
+			return err
 		}
-		f, err := newFile(tr, hdr.Name, hdr.Uname, hdr.Gname, hdr.FileInfo().Mode(), manifest.NotSpecified)
-		if err != nil {
-			return nil, err
-		}
-		results.files = append(results.files, f)
 	}
 
-	return results, nil
+	return nil
 }
 
-func (b *dockerBuilder) Run() (Results, error) {
+func (b *dockerBuilder) run() error {
 	log.Info().Msg("Starting docker build")
 	log.Info().Msg("Building docker image")
 
@@ -339,22 +605,38 @@ func (b *dockerBuilder) Run() (Results, error) {
 		log.Info().Msg(resp.String())
 	} else {
 		log.Error().Msgf("Error building docker image")
-		return nil, err
+		return err
 	}
 	log.Info().Msg("Running docker container")
 	if err := b.exec(); err != nil {
 		log.Error().Msg("Error running docker container")
-		return nil, err
+		return err
 	}
 	log.Info().Msg("Cleaning up")
 	if err := b.remove(); err != nil {
 		log.Error().Msg("Error removing docker image")
-		return nil, err
+		return err
 	}
 	log.Info().Msg("Docker build ran successfully")
+	return nil
+}
+
+func (b *dockerBuilder) Run() (Results, error) {
+	defer b.cleanupScratch()
+	if err := b.run(); err != nil {
+		return nil, err
+	}
 	return b.extractResults()
 }
 
+func (b *dockerBuilder) RunLabeled() (map[string]Results, error) {
+	defer b.cleanupScratch()
+	if err := b.run(); err != nil {
+		return nil, err
+	}
+	return b.extractLabeledResults()
+}
+
 // DockerBuildOption specifies options for a Docker Build
 type DockerBuildOption interface {
 	Apply(build interface{}) error
@@ -411,15 +693,36 @@ func (o *dockerTagOption) Apply(build interface{}) error {
 	if !ok {
 		return errors.New("unexpected error")
 	}
+	if err := ValidateImageRef(o.tag); err != nil {
+		return err
+	}
 	b.tags = append(b.tags, o.tag)
 	return nil
 }
 
-// WithDockerTag specifies a tag
+// WithDockerTag specifies a tag, which must be a valid image reference
 func WithDockerTag(tag string) DockerBuildOption {
 	return &dockerTagOption{tag: tag}
 }
 
+type dockerContentTagOption struct {
+	prefix string
+}
+
+func (o *dockerContentTagOption) Apply(build interface{}) error {
+	b, ok := build.(*dockerBuilder)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	b.contentTag = o.prefix
+	return nil
+}
+
+// WithContentTag tags the image as prefix:<hash> where the hash is derived from the build context and build args
+func WithContentTag(prefix string) DockerBuildOption {
+	return &dockerContentTagOption{prefix: prefix}
+}
+
 type dockerBuildArgOption struct {
 	name  string
 	value string
@@ -439,6 +742,56 @@ func WithDockerBuildArg(name, value string) DockerBuildOption {
 	return &dockerBuildArgOption{name: name, value: value}
 }
 
+type dockerBuildArgsOption struct {
+	args map[string]string
+}
+
+func (o *dockerBuildArgsOption) Apply(build interface{}) error {
+	b, ok := build.(*dockerBuilder)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	for name, value := range o.args {
+		value := value
+		b.buildArgs[name] = &value
+	}
+	return nil
+}
+
+// WithBuildArgs specifies optional docker build args, empty values are passed as set rather than unset
+func WithBuildArgs(args map[string]string) DockerBuildOption {
+	return &dockerBuildArgsOption{args: args}
+}
+
+type dockerBuildArgsFromEnvOption struct {
+	env string
+}
+
+func (o *dockerBuildArgsFromEnvOption) Apply(build interface{}) error {
+	b, ok := build.(*dockerBuilder)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	pairs, err := keyvalue.ParsePairSlice(o.env, keyvalue.RemoveOuterQuotes)
+	if err != nil {
+		return err
+	}
+	args, err := pairs.ToMap()
+	if err != nil {
+		return err
+	}
+	for name, value := range args {
+		value := value
+		b.buildArgs[name] = &value
+	}
+	return nil
+}
+
+// WithBuildArgsFromEnv specifies docker build args from a newline delimited KEY=VALUE string such as a .env file
+func WithBuildArgsFromEnv(env string) DockerBuildOption {
+	return &dockerBuildArgsFromEnvOption{env: env}
+}
+
 type dockerBuildEnvOption struct {
 	value string
 }
@@ -457,6 +810,207 @@ func WithDockerEnv(value string) DockerBuildOption {
 	return &dockerBuildEnvOption{value: value}
 }
 
+type dockerWorkingDirOption struct {
+	dir string
+}
+
+func (o *dockerWorkingDirOption) Apply(build interface{}) error {
+	b, ok := build.(*dockerBuilder)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	b.workingDir = o.dir
+	return nil
+}
+
+// WithWorkingDir specifies the working directory of the build container
+func WithWorkingDir(dir string) DockerBuildOption {
+	return &dockerWorkingDirOption{dir: dir}
+}
+
+type dockerUserOption struct {
+	user string
+}
+
+func (o *dockerUserOption) Apply(build interface{}) error {
+	b, ok := build.(*dockerBuilder)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	b.user = o.user
+	return nil
+}
+
+// WithUser specifies the user (name or uid[:gid]) the build container runs as
+func WithUser(user string) DockerBuildOption {
+	return &dockerUserOption{user: user}
+}
+
+type dockerStripComponentsOption struct {
+	n int
+}
+
+func (o *dockerStripComponentsOption) Apply(build interface{}) error {
+	b, ok := build.(*dockerBuilder)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	if o.n < 0 {
+		return errors.New("strip components cannot be negative")
+	}
+	b.stripComponents = o.n
+	return nil
+}
+
+// WithStripComponents strips the specified number of leading components from extracted file names
+func WithStripComponents(n int) DockerBuildOption {
+	return &dockerStripComponentsOption{n: n}
+}
+
+type dockerfileValidationOption struct{}
+
+func (o *dockerfileValidationOption) Apply(build interface{}) error {
+	b, ok := build.(*dockerBuilder)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	b.validateDockerfile = true
+	return nil
+}
+
+// WithDockerfileValidation validates the Dockerfile when the build is created
+func WithDockerfileValidation() DockerBuildOption {
+	return &dockerfileValidationOption{}
+}
+
+type dockerSkipUnsafePathsOption struct{}
+
+func (o *dockerSkipUnsafePathsOption) Apply(build interface{}) error {
+	b, ok := build.(*dockerBuilder)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	b.skipUnsafePaths = true
+	return nil
+}
+
+// WithSkipUnsafePaths skips extracted entries which escape the output directory with a warning instead of failing
+func WithSkipUnsafePaths() DockerBuildOption {
+	return &dockerSkipUnsafePathsOption{}
+}
+
+type dockerLenientExtractionOption struct{}
+
+func (o *dockerLenientExtractionOption) Apply(build interface{}) error {
+	b, ok := build.(*dockerBuilder)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	b.lenient = true
+	return nil
+}
+
+// WithLenientExtraction skips output entries which cannot be read with a warning, Run then returns the
+// partial results along with an error listing the skipped entries
+func WithLenientExtraction() DockerBuildOption {
+	return &dockerLenientExtractionOption{}
+}
+
+type dockerAllowEmptyOutputOption struct{}
+
+func (o *dockerAllowEmptyOutputOption) Apply(build interface{}) error {
+	b, ok := build.(*dockerBuilder)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	b.allowEmpty = true
+	return nil
+}
+
+// WithAllowEmptyOutput allows output paths which are missing or contain no files
+func WithAllowEmptyOutput() DockerBuildOption {
+	return &dockerAllowEmptyOutputOption{}
+}
+
+type dockerScratchDirOption struct {
+	path string
+}
+
+func (o *dockerScratchDirOption) Apply(build interface{}) error {
+	b, ok := build.(*dockerBuilder)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	b.scratchDir = o.path
+	return nil
+}
+
+// WithScratchDir buffers the build context and extracted outputs in temporary files under path
+// instead of in memory
+func WithScratchDir(path string) DockerBuildOption {
+	return &dockerScratchDirOption{path: path}
+}
+
+type dockerClientOption struct {
+	client dockerAPI
+}
+
+func (o *dockerClientOption) Apply(build interface{}) error {
+	b, ok := build.(*dockerBuilder)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	b.client = o.client
+	return nil
+}
+
+// WithDockerClient specifies the docker client to use instead of one created from the environment
+func WithDockerClient(client dockerAPI) DockerBuildOption {
+	return &dockerClientOption{client: client}
+}
+
+type dockerExtractPathsOption struct {
+	paths []string
+}
+
+func (o *dockerExtractPathsOption) Apply(build interface{}) error {
+	b, ok := build.(*dockerBuilder)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	b.extractPaths = append(b.extractPaths, o.paths...)
+	return nil
+}
+
+// WithExtractPaths specifies individual paths to copy from the container instead of the output directory
+func WithExtractPaths(paths ...string) DockerBuildOption {
+	return &dockerExtractPathsOption{paths: paths}
+}
+
+type dockerLabeledOutputOption struct {
+	label string
+	path  string
+}
+
+func (o *dockerLabeledOutputOption) Apply(build interface{}) error {
+	b, ok := build.(*dockerBuilder)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	for _, output := range b.labeledOutputs {
+		if output.label == o.label {
+			return fmt.Errorf("duplicate output label %s", o.label)
+		}
+	}
+	b.labeledOutputs = append(b.labeledOutputs, &dockerOutput{label: o.label, path: o.path})
+	return nil
+}
+
+// WithLabeledOutput specifies an additional output directory whose results are returned under label by RunLabeled
+func WithLabeledOutput(label, path string) DockerBuildOption {
+	return &dockerLabeledOutputOption{label: label, path: path}
+}
+
 // NewDockerBuild creates a new Docker Build
 func NewDockerBuild(dockerFile, outputDirectory string, options ...DockerBuildOption) (Build, error) {
 	out := &dockerBuilder{
@@ -467,14 +1021,22 @@ func NewDockerBuild(dockerFile, outputDirectory string, options ...DockerBuildOp
 		buildArgs:       map[string]*string{},
 		env:             []string{},
 		outputDirectory: outputDirectory,
+		extractPaths:    []string{},
+		labeledOutputs:  []*dockerOutput{},
+		removeBackoff:   defaultRemoveBackoff,
 	}
 	for _, opt := range options {
 		if err := opt.Apply(out); err != nil {
 			return nil, err
 		}
 	}
-	if out.outputDirectory == "" {
-		return nil, fmt.Errorf("must specify an output directory")
+	if out.validateDockerfile {
+		if err := ValidateDockerfile(out.dockerFile); err != nil {
+			return nil, err
+		}
+	}
+	if len(out.outputPaths()) == 0 {
+		return nil, fmt.Errorf("must specify an output directory, extract paths or labeled outputs")
 	}
 	return out, nil
 }
@@ -0,0 +1,798 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/limejuice-cc/limepacker/compression"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testDockerFile   = "FROM alpine:latest\nRUN mkdir -p /output && echo test > /output/test.txt\n"
+	testBuildOutputs = `{"stream":"Step 1/2 : FROM alpine:latest"}
+{"stream":"\n"}
+{"aux":{"ID":"sha256:0123456789abcdef"}}
+{"stream":"Successfully built 0123456789ab"}
+`
+)
+
+type testTarEntry struct {
+	name     string
+	body     string
+	mode     int64
+	typeflag byte
+	linkname string
+	uname    string
+	gname    string
+	uid      int
+	gid      int
+}
+
+func newTestTar(entries ...testTarEntry) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		mode := e.mode
+		if mode == 0 {
+			mode = 0644
+		}
+		hdr := &tar.Header{Name: e.name, Mode: mode, Size: int64(len(e.body)), Typeflag: tar.TypeReg, Uname: e.uname, Gname: e.gname, Uid: e.uid, Gid: e.gid}
+		if strings.HasSuffix(e.name, "/") {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Size = 0
+		}
+		if e.typeflag != 0 {
+			hdr.Typeflag = e.typeflag
+			hdr.Linkname = e.linkname
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			panic(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			panic(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+type fakeDockerClient struct {
+	outputs map[string][]byte
+
+	buildContext   []byte
+	buildOptions   types.ImageBuildOptions
+	config         *container.Config
+	copied         []string
+	started        bool
+	stopped        bool
+	removed        bool
+	removedImageID string
+	removeCalls    int
+	removeErrors   []error
+
+	// scratchDir is listed into scratchFiles when the container is stopped
+	scratchDir   string
+	scratchFiles []string
+}
+
+func newFakeDockerClient(outputs map[string][]byte) *fakeDockerClient {
+	return &fakeDockerClient{outputs: outputs, copied: []string{}}
+}
+
+func (c *fakeDockerClient) ImageBuild(ctx context.Context, buildContext io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error) {
+	body, err := ioutil.ReadAll(buildContext)
+	if err != nil {
+		return types.ImageBuildResponse{}, err
+	}
+	c.buildContext = body
+	c.buildOptions = options
+	return types.ImageBuildResponse{Body: ioutil.NopCloser(strings.NewReader(testBuildOutputs))}, nil
+}
+
+func (c *fakeDockerClient) ImageRemove(ctx context.Context, imageID string, options types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error) {
+	c.removedImageID = imageID
+	c.removeCalls++
+	if len(c.removeErrors) > 0 {
+		err := c.removeErrors[0]
+		c.removeErrors = c.removeErrors[1:]
+		return nil, err
+	}
+	return []types.ImageDeleteResponseItem{{Deleted: imageID}}, nil
+}
+
+func (c *fakeDockerClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *specs.Platform, containerName string) (container.ContainerCreateCreatedBody, error) {
+	c.config = config
+	return container.ContainerCreateCreatedBody{ID: "test-container"}, nil
+}
+
+func (c *fakeDockerClient) ContainerStart(ctx context.Context, containerID string, options types.ContainerStartOptions) error {
+	c.started = true
+	return nil
+}
+
+func (c *fakeDockerClient) ContainerStop(ctx context.Context, containerID string, timeout *time.Duration) error {
+	if c.scratchDir != "" {
+		entries, err := ioutil.ReadDir(c.scratchDir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			c.scratchFiles = append(c.scratchFiles, e.Name())
+		}
+	}
+	c.stopped = true
+	return nil
+}
+
+func (c *fakeDockerClient) ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error {
+	c.removed = true
+	return nil
+}
+
+func (c *fakeDockerClient) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, types.ContainerPathStat, error) {
+	c.copied = append(c.copied, srcPath)
+	out, ok := c.outputs[srcPath]
+	if !ok {
+		return nil, types.ContainerPathStat{}, testNotFoundError("no such container path: " + srcPath)
+	}
+	return ioutil.NopCloser(bytes.NewReader(out)), types.ContainerPathStat{Name: srcPath}, nil
+}
+
+// testNotFoundError is returned by the fake for missing images and container paths, like the daemon's errors
+// it satisfies client.IsErrNotFound
+type testNotFoundError string
+
+func (e testNotFoundError) Error() string { return string(e) }
+func (testNotFoundError) NotFound()       {}
+
+func TestDockerBuildRun(t *testing.T) {
+	cli := newFakeDockerClient(map[string][]byte{
+		"/output": newTestTar(
+			testTarEntry{name: "output/"},
+			testTarEntry{name: "output/test.txt", body: "test\n"},
+		),
+	})
+
+	b, err := NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithDockerEnv("TEST=1"))
+	if assert.NoError(t, err) {
+		results, err := b.Run()
+		if assert.NoError(t, err) {
+			if assert.Len(t, results.Files(), 1) {
+				f := results.Files()[0]
+				assert.Equal(t, "output/test.txt", f.Name())
+				assert.Equal(t, []byte("test\n"), f.Body())
+			}
+		}
+	}
+
+	assert.NotEmpty(t, cli.buildContext)
+	assert.Equal(t, "Dockerfile", cli.buildOptions.Dockerfile)
+	assert.Equal(t, "0123456789abcdef", cli.config.Image)
+	assert.Equal(t, []string{"TEST=1"}, cli.config.Env)
+	assert.Equal(t, []string{"/output"}, cli.copied)
+	assert.True(t, cli.started)
+	assert.True(t, cli.stopped)
+	assert.True(t, cli.removed)
+	assert.Equal(t, "0123456789abcdef", cli.removedImageID)
+
+	_, err = NewDockerBuild(testDockerFile, "")
+	assert.Error(t, err)
+}
+
+func TestDockerBuildExtractPaths(t *testing.T) {
+	cli := newFakeDockerClient(map[string][]byte{
+		"/output": newTestTar(
+			testTarEntry{name: "output/unused.txt", body: "unused\n"},
+		),
+		"/output/bin/app": newTestTar(
+			testTarEntry{name: "app", body: "app\n", mode: 0755},
+		),
+		"/output/etc/app.conf": newTestTar(
+			testTarEntry{name: "app.conf", body: "conf\n"},
+		),
+	})
+
+	b, err := NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithExtractPaths("/output/bin/app", "/output/etc/app.conf"))
+	if assert.NoError(t, err) {
+		results, err := b.Run()
+		if assert.NoError(t, err) && assert.Len(t, results.Files(), 2) {
+			assert.Equal(t, "app", results.Files()[0].Name())
+			assert.Equal(t, "app.conf", results.Files()[1].Name())
+		}
+	}
+	assert.Equal(t, []string{"/output/bin/app", "/output/etc/app.conf"}, cli.copied)
+
+	_, err = NewDockerBuild(testDockerFile, "", WithExtractPaths("/output/bin/app"))
+	assert.NoError(t, err)
+}
+
+func TestDockerBuildLabeledOutputs(t *testing.T) {
+	cli := newFakeDockerClient(map[string][]byte{
+		"/output/bin": newTestTar(
+			testTarEntry{name: "bin/"},
+			testTarEntry{name: "bin/app", body: "app\n", mode: 0755},
+			testTarEntry{name: "bin/tool", body: "tool\n", mode: 0755},
+		),
+		"/output/etc": newTestTar(
+			testTarEntry{name: "etc/app.conf", body: "conf\n"},
+		),
+	})
+
+	b, err := NewDockerBuild(testDockerFile, "", WithDockerClient(cli), WithLabeledOutput("bin", "/output/bin"), WithLabeledOutput("config", "/output/etc"))
+	if assert.NoError(t, err) {
+		lb, ok := b.(LabeledBuild)
+		if assert.True(t, ok) {
+			results, err := lb.RunLabeled()
+			if assert.NoError(t, err) && assert.Len(t, results, 2) {
+				if assert.Len(t, results["bin"].Files(), 2) {
+					assert.Equal(t, "bin/app", results["bin"].Files()[0].Name())
+					assert.Equal(t, "bin/tool", results["bin"].Files()[1].Name())
+				}
+				if assert.Len(t, results["config"].Files(), 1) {
+					assert.Equal(t, "etc/app.conf", results["config"].Files()[0].Name())
+				}
+			}
+		}
+	}
+	assert.Equal(t, []string{"/output/bin", "/output/etc"}, cli.copied)
+
+	_, err = NewDockerBuild(testDockerFile, "", WithLabeledOutput("bin", "/output/bin"), WithLabeledOutput("bin", "/output/etc"))
+	assert.Error(t, err)
+}
+
+func TestDockerBuildContextSize(t *testing.T) {
+	dockerIgnore := "# ignore logs\n*.log\n"
+	b, err := NewDockerBuild(testDockerFile, "/output",
+		WithDockerIgnore(dockerIgnore),
+		WitExtrahFile("app.conf", strings.NewReader("conf\n")),
+		WitExtrahFile("build.sh", strings.NewReader("#!/bin/sh\necho build\n")),
+		WitExtrahFile("build.log", strings.NewReader("ignored\n")),
+	)
+	if assert.NoError(t, err) {
+		size, count, err := b.(*dockerBuilder).ContextSize()
+		if assert.NoError(t, err) {
+			assert.Equal(t, 4, count)
+			assert.Equal(t, int64(len(testDockerFile)+len(dockerIgnore)+len("conf\n")+len("#!/bin/sh\necho build\n")), size)
+		}
+
+		ctx, err := b.(*dockerBuilder).createContext()
+		if assert.NoError(t, err) {
+			names := []string{}
+			tr := tar.NewReader(ctx)
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if !assert.NoError(t, err) {
+					break
+				}
+				names = append(names, hdr.Name)
+			}
+			assert.Equal(t, []string{"Dockerfile", ".dockerignore", "app.conf", "build.sh"}, names)
+		}
+	}
+}
+
+func TestDockerBuildWorkingDirAndUser(t *testing.T) {
+	cli := newFakeDockerClient(map[string][]byte{
+		"/output": newTestTar(testTarEntry{name: "output/test.txt", body: "test\n"}),
+	})
+
+	b, err := NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithWorkingDir("/src"), WithUser("1000:1000"))
+	if assert.NoError(t, err) {
+		_, err := b.Run()
+		if assert.NoError(t, err) {
+			assert.Equal(t, "/src", cli.config.WorkingDir)
+			assert.Equal(t, "1000:1000", cli.config.User)
+		}
+	}
+}
+
+func TestDockerBuildStripComponents(t *testing.T) {
+	cli := newFakeDockerClient(map[string][]byte{
+		"/output": newTestTar(
+			testTarEntry{name: "output/"},
+			testTarEntry{name: "output/top.txt", body: "top\n"},
+			testTarEntry{name: "output/bin/app", body: "app\n", mode: 0755},
+			testTarEntry{name: "output/./etc//app.conf", body: "conf\n"},
+		),
+	})
+
+	b, err := NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithStripComponents(1))
+	if assert.NoError(t, err) {
+		results, err := b.Run()
+		if assert.NoError(t, err) {
+			names := []string{}
+			for _, f := range results.Files() {
+				names = append(names, f.Name())
+			}
+			assert.Equal(t, []string{"top.txt", "bin/app", "etc/app.conf"}, names)
+		}
+	}
+
+	b, err = NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithStripComponents(2))
+	if assert.NoError(t, err) {
+		results, err := b.Run()
+		if assert.NoError(t, err) {
+			names := []string{}
+			for _, f := range results.Files() {
+				names = append(names, f.Name())
+			}
+			assert.Equal(t, []string{"app", "app.conf"}, names)
+		}
+	}
+
+	_, err = NewDockerBuild(testDockerFile, "/output", WithStripComponents(-1))
+	assert.Error(t, err)
+}
+
+func TestDockerBuildLinks(t *testing.T) {
+	cli := newFakeDockerClient(map[string][]byte{
+		"/output": newTestTar(
+			testTarEntry{name: "output/bin/busybox", body: "busybox\n", mode: 0755},
+			testTarEntry{name: "output/usr/bin/foo", mode: 0777, typeflag: tar.TypeSymlink, linkname: "../../bin/busybox"},
+			testTarEntry{name: "output/bin/sh", mode: 0755, typeflag: tar.TypeLink, linkname: "output/bin/busybox"},
+		),
+	})
+
+	b, err := NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithStripComponents(1))
+	if !assert.NoError(t, err) {
+		return
+	}
+	results, err := b.Run()
+	if !assert.NoError(t, err) || !assert.Len(t, results.Files(), 3) {
+		return
+	}
+	symlink, hardlink := results.Files()[1], results.Files()[2]
+	assert.Empty(t, results.Files()[0].LinkTarget())
+	assert.Equal(t, "usr/bin/foo", symlink.Name())
+	assert.Equal(t, "../../bin/busybox", symlink.LinkTarget())
+	assert.NotZero(t, symlink.Mode()&os.ModeSymlink)
+	assert.Equal(t, "bin/sh", hardlink.Name())
+	assert.Equal(t, "bin/busybox", hardlink.LinkTarget())
+	assert.Zero(t, hardlink.Mode()&os.ModeSymlink)
+
+	var buf bytes.Buffer
+	assert.NoError(t, results.WriteArchive(&buf, compression.None))
+	opened, err := OpenArchiveStream(&buf, compression.None)
+	if assert.NoError(t, err) && assert.Len(t, opened.Files(), 3) {
+		for i, f := range results.Files() {
+			assert.Equal(t, f.LinkTarget(), opened.Files()[i].LinkTarget())
+			assert.Equal(t, f.Mode()&os.ModeSymlink, opened.Files()[i].Mode()&os.ModeSymlink)
+		}
+	}
+
+	cli = newFakeDockerClient(map[string][]byte{
+		"/output": newTestTar(testTarEntry{name: "output/bin/sh", typeflag: tar.TypeLink, linkname: "../etc/shadow"}),
+	})
+	b, err = NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli))
+	if assert.NoError(t, err) {
+		_, err = b.Run()
+		assert.Error(t, err)
+	}
+}
+
+func TestDockerBuildDirectories(t *testing.T) {
+	cli := newFakeDockerClient(map[string][]byte{
+		"/output": newTestTar(
+			testTarEntry{name: "output/"},
+			testTarEntry{name: "output/var/log/myapp/", mode: 0750, uname: "myapp", gname: "adm"},
+			testTarEntry{name: "output/etc/myapp.conf", body: "conf\n"},
+		),
+	})
+
+	b, err := NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithStripComponents(1))
+	if !assert.NoError(t, err) {
+		return
+	}
+	results, err := b.Run()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, results.Files(), 1)
+	if assert.Len(t, results.Directories(), 1) {
+		d := results.Directories()[0]
+		assert.Equal(t, "var/log/myapp", d.Name())
+		assert.Equal(t, os.ModeDir|0750, d.Mode())
+		assert.Equal(t, "myapp", d.User())
+		assert.Equal(t, "adm", d.Group())
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, results.WriteArchive(&buf, compression.None))
+	opened, err := OpenArchiveStream(&buf, compression.None)
+	if assert.NoError(t, err) && assert.Len(t, opened.Directories(), 1) {
+		assert.Equal(t, results.Directories()[0].Name(), opened.Directories()[0].Name())
+		assert.Equal(t, results.Directories()[0].Mode(), opened.Directories()[0].Mode())
+		assert.Equal(t, "myapp", opened.Directories()[0].User())
+		assert.Len(t, opened.Files(), 1)
+	}
+
+	normalized := results.NormalizePermissions(PermissionPolicy{})
+	assert.Len(t, normalized.Directories(), 1)
+}
+
+func TestDockerBuildNumericOwnership(t *testing.T) {
+	cli := newFakeDockerClient(map[string][]byte{
+		"/output": newTestTar(
+			testTarEntry{name: "output/data/", uid: 1001, gid: 1002},
+			testTarEntry{name: "output/data/state.db", body: "state\n", uid: 1001, gid: 1002},
+			testTarEntry{name: "output/etc/app.conf", body: "conf\n", uname: "root", gname: "root"},
+		),
+	})
+
+	b, err := NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli))
+	if !assert.NoError(t, err) {
+		return
+	}
+	results, err := b.Run()
+	if !assert.NoError(t, err) || !assert.Len(t, results.Files(), 2) || !assert.Len(t, results.Directories(), 1) {
+		return
+	}
+	for _, f := range []File{results.Directories()[0], results.Files()[0]} {
+		assert.Empty(t, f.User())
+		assert.Equal(t, 1001, f.UID())
+		assert.Equal(t, 1002, f.GID())
+	}
+	assert.Equal(t, "root", results.Files()[1].User())
+	assert.Zero(t, results.Files()[1].UID())
+
+	var buf bytes.Buffer
+	assert.NoError(t, results.WriteArchive(&buf, compression.None))
+	opened, err := OpenArchiveStream(&buf, compression.None)
+	if assert.NoError(t, err) && assert.Len(t, opened.Files(), 2) {
+		assert.Equal(t, 1001, opened.Files()[0].UID())
+		assert.Equal(t, 1002, opened.Files()[0].GID())
+		assert.Equal(t, 1001, opened.Directories()[0].UID())
+	}
+}
+
+func TestDockerBuildDockerfileValidation(t *testing.T) {
+	_, err := NewDockerBuild("RUN echo test\n", "/output")
+	assert.NoError(t, err)
+
+	_, err = NewDockerBuild("RUN echo test\n", "/output", WithDockerfileValidation())
+	assert.Error(t, err)
+
+	_, err = NewDockerBuild(testDockerFile, "/output", WithDockerfileValidation())
+	assert.NoError(t, err)
+}
+
+func TestDockerBuildUnsafePaths(t *testing.T) {
+	cli := newFakeDockerClient(map[string][]byte{
+		"/output": newTestTar(
+			testTarEntry{name: "output/test.txt", body: "test\n"},
+			testTarEntry{name: "../../etc/passwd", body: "root:x:0:0::/root:/bin/sh\n"},
+			testTarEntry{name: "output/../../escape.txt", body: "escape\n"},
+		),
+	})
+
+	b, err := NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli))
+	if assert.NoError(t, err) {
+		_, err := b.Run()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "../../etc/passwd")
+		}
+	}
+
+	b, err = NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithSkipUnsafePaths())
+	if assert.NoError(t, err) {
+		results, err := b.Run()
+		if assert.NoError(t, err) && assert.Len(t, results.Files(), 1) {
+			assert.Equal(t, "output/test.txt", results.Files()[0].Name())
+		}
+	}
+}
+
+func TestDockerBuildEmptyOutput(t *testing.T) {
+	cli := newFakeDockerClient(map[string][]byte{
+		"/output": newTestTar(
+			testTarEntry{name: "output/"},
+		),
+	})
+
+	b, err := NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli))
+	if assert.NoError(t, err) {
+		_, err := b.Run()
+		if assert.Error(t, err) {
+			assert.Equal(t, "output directory /output not found in container or empty", err.Error())
+		}
+	}
+
+	b, err = NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithAllowEmptyOutput())
+	if assert.NoError(t, err) {
+		results, err := b.Run()
+		if assert.NoError(t, err) {
+			assert.Empty(t, results.Files())
+		}
+	}
+}
+
+func TestDockerBuildMissingOutput(t *testing.T) {
+	cli := newFakeDockerClient(map[string][]byte{})
+
+	b, err := NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli))
+	if assert.NoError(t, err) {
+		_, err := b.Run()
+		if assert.Error(t, err) {
+			assert.Equal(t, "output directory /output not found in container or empty", err.Error())
+		}
+	}
+	assert.True(t, cli.stopped)
+	assert.True(t, cli.removed)
+
+	cli = newFakeDockerClient(map[string][]byte{})
+	b, err = NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithAllowEmptyOutput())
+	if assert.NoError(t, err) {
+		results, err := b.Run()
+		if assert.NoError(t, err) {
+			assert.Empty(t, results.Files())
+		}
+	}
+	assert.True(t, cli.stopped)
+	assert.True(t, cli.removed)
+}
+
+func TestDockerBuildArgs(t *testing.T) {
+	cli := newFakeDockerClient(map[string][]byte{"/output": newTestTar(testTarEntry{name: "output/test.txt", body: "test\n"})})
+	b, err := NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli),
+		WithDockerBuildArg("VERSION", "1.2.3"),
+		WithBuildArgs(map[string]string{"CHANNEL": "stable", "EMPTY": ""}),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = b.Run()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	args := cli.buildOptions.BuildArgs
+	if assert.Len(t, args, 3) {
+		for name, expected := range map[string]string{"VERSION": "1.2.3", "CHANNEL": "stable", "EMPTY": ""} {
+			if assert.NotNil(t, args[name], name) {
+				assert.Equal(t, expected, *args[name])
+			}
+		}
+	}
+	_, unset := args["UNSET"]
+	assert.False(t, unset)
+}
+
+func TestDockerBuildArgsFromEnv(t *testing.T) {
+	env := `
+# build settings
+VERSION=1.2.3
+NAME="lime packer"
+CHANNEL='stable'
+`
+	b, err := NewDockerBuild(testDockerFile, "/output", WithBuildArgsFromEnv(env))
+	if assert.NoError(t, err) {
+		buildArgs := b.(*dockerBuilder).buildArgs
+		if assert.Len(t, buildArgs, 3) {
+			assert.Equal(t, "1.2.3", *buildArgs["VERSION"])
+			assert.Equal(t, "lime packer", *buildArgs["NAME"])
+			assert.Equal(t, "stable", *buildArgs["CHANNEL"])
+		}
+	}
+
+	_, err = NewDockerBuild(testDockerFile, "/output", WithBuildArgsFromEnv("VERSION=1\nVERSION=2"))
+	assert.Error(t, err)
+	_, err = NewDockerBuild(testDockerFile, "/output", WithBuildArgsFromEnv("INVALID"))
+	assert.Error(t, err)
+}
+
+func TestDockerBuildScratchDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scratch")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	cli := newFakeDockerClient(map[string][]byte{
+		"/output": newTestTar(
+			testTarEntry{name: "output/test.txt", body: "test\n"},
+		),
+	})
+	cli.scratchDir = dir
+
+	b, err := NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithScratchDir(dir))
+	if assert.NoError(t, err) {
+		results, err := b.Run()
+		if assert.NoError(t, err) && assert.Len(t, results.Files(), 1) {
+			assert.Equal(t, []byte("test\n"), results.Files()[0].Body())
+		}
+	}
+	assert.NotEmpty(t, cli.buildContext)
+	if assert.Len(t, cli.scratchFiles, 2) {
+		assert.True(t, strings.HasPrefix(cli.scratchFiles[0], "context-"))
+		assert.True(t, strings.HasPrefix(cli.scratchFiles[1], "output-"))
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if assert.NoError(t, err) {
+		assert.Empty(t, entries)
+	}
+
+	defer func(threshold int64) { streamingThreshold = threshold }(streamingThreshold)
+	streamingThreshold = 2
+	b, err = NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithScratchDir(dir))
+	if assert.NoError(t, err) {
+		results, err := b.Run()
+		if assert.NoError(t, err) && assert.Len(t, results.Files(), 1) {
+			assert.Equal(t, []byte("test\n"), results.Files()[0].Body())
+			entries, err := ioutil.ReadDir(dir)
+			if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+				assert.True(t, strings.HasPrefix(entries[0].Name(), "limepacker-file-"))
+			}
+			assert.NoError(t, results.Close())
+		}
+	}
+	entries, err = ioutil.ReadDir(dir)
+	if assert.NoError(t, err) {
+		assert.Empty(t, entries)
+	}
+}
+
+func TestDockerBuildContentTag(t *testing.T) {
+	contentTag := func(options ...DockerBuildOption) string {
+		cli := newFakeDockerClient(map[string][]byte{
+			"/output": newTestTar(testTarEntry{name: "output/test.txt", body: "test\n"}),
+		})
+		options = append(options, WithDockerClient(cli), WithContentTag("limepacker/test"), WithDockerTag("limepacker/test:latest"))
+		b, err := NewDockerBuild(testDockerFile, "/output", options...)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		_, err = b.Run()
+		assert.NoError(t, err)
+		if !assert.Len(t, cli.buildOptions.Tags, 2) {
+			t.FailNow()
+		}
+		assert.Equal(t, "limepacker/test:latest", cli.buildOptions.Tags[0])
+		return cli.buildOptions.Tags[1]
+	}
+
+	tag := contentTag(WithDockerBuildArg("VERSION", "1"), WitExtrahFile("test.txt", strings.NewReader("test")))
+	assert.Regexp(t, "^limepacker/test:[0-9a-f]{64}$", tag)
+	assert.Equal(t, tag, contentTag(WithDockerBuildArg("VERSION", "1"), WitExtrahFile("test.txt", strings.NewReader("test"))))
+	assert.NotEqual(t, tag, contentTag(WithDockerBuildArg("VERSION", "2"), WitExtrahFile("test.txt", strings.NewReader("test"))))
+	assert.NotEqual(t, tag, contentTag(WithDockerBuildArg("VERSION", "1"), WitExtrahFile("test.txt", strings.NewReader("changed"))))
+}
+
+func TestDockerBuildLenientExtraction(t *testing.T) {
+	archive := newTestTar(
+		testTarEntry{name: "output/first.txt", body: "first\n"},
+		testTarEntry{name: "output/corrupt.txt", body: "corrupt\n"},
+		testTarEntry{name: "output/second.txt", body: "second\n"},
+		testTarEntry{name: "output/third.txt", body: "third\n"},
+	)
+	// corrupt the checksum of the second entry's header which follows the first header and body block
+	copy(archive[2*512+148:], "9999999")
+
+	cli := newFakeDockerClient(map[string][]byte{"/output": archive})
+
+	b, err := NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli))
+	if assert.NoError(t, err) {
+		_, err := b.Run()
+		assert.Error(t, err)
+	}
+
+	b, err = NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithLenientExtraction())
+	if assert.NoError(t, err) {
+		results, err := b.Run()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "1 build output entries could not be extracted")
+		}
+		if assert.NotNil(t, results) && assert.Len(t, results.Files(), 3) {
+			assert.Equal(t, "output/first.txt", results.Files()[0].Name())
+			assert.Equal(t, "output/second.txt", results.Files()[1].Name())
+			assert.Equal(t, "output/third.txt", results.Files()[2].Name())
+		}
+	}
+}
+
+func TestDockerBuildLenientExtractionEmbeddedTar(t *testing.T) {
+	inner := newTestTar(testTarEntry{name: "output/embedded.txt", body: "embedded\n"})
+	archive := newTestTar(
+		testTarEntry{name: "output/first.txt", body: "first\n"},
+		testTarEntry{name: "output/bundle.tar", body: string(inner)},
+		testTarEntry{name: "output/corrupt.txt", body: "corrupt\n"},
+		testTarEntry{name: "output/last.txt", body: "last\n"},
+	)
+	// corrupt the checksum of the header following the bundle so extraction resyncs after it
+	copy(archive[3*512+len(inner)+148:], "9999999")
+
+	cli := newFakeDockerClient(map[string][]byte{"/output": archive})
+	b, err := NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithLenientExtraction())
+	if assert.NoError(t, err) {
+		results, err := b.Run()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "1 build output entries could not be extracted")
+		}
+		if assert.NotNil(t, results) && assert.Len(t, results.Files(), 3) {
+			assert.Equal(t, "output/first.txt", results.Files()[0].Name())
+			assert.Equal(t, "output/bundle.tar", results.Files()[1].Name())
+			assert.Equal(t, "output/last.txt", results.Files()[2].Name())
+			body, err := ioutil.ReadAll(results.Files()[1].Reader())
+			if assert.NoError(t, err) {
+				assert.Equal(t, inner, body)
+			}
+		}
+	}
+
+	// a stripped entry's body is never read so the next header must be found from its size
+	archive = newTestTar(
+		testTarEntry{name: "bundle.tar", body: string(inner)},
+		testTarEntry{name: "output/corrupt.txt", body: "corrupt\n"},
+		testTarEntry{name: "output/last.txt", body: "last\n"},
+	)
+	copy(archive[512+len(inner)+148:], "9999999")
+
+	cli = newFakeDockerClient(map[string][]byte{"/output": archive})
+	b, err = NewDockerBuild(testDockerFile, "/output", WithDockerClient(cli), WithLenientExtraction(), WithStripComponents(1))
+	if assert.NoError(t, err) {
+		results, err := b.Run()
+		assert.Error(t, err)
+		if assert.NotNil(t, results) && assert.Len(t, results.Files(), 1) {
+			assert.Equal(t, "last.txt", results.Files()[0].Name())
+		}
+	}
+}
+
+func TestDockerRemoveRetries(t *testing.T) {
+	cli := newFakeDockerClient(nil)
+	b := &dockerBuilder{client: cli, imageID: "0123456789abcdef", removeBackoff: time.Millisecond}
+
+	cli.removeErrors = []error{errors.New("conflict: image is being used by running container")}
+	assert.NoError(t, b.remove())
+	assert.Equal(t, 2, cli.removeCalls)
+	assert.Equal(t, "0123456789abcdef", cli.removedImageID)
+
+	cli.removeCalls = 0
+	cli.removeErrors = []error{testNotFoundError("no such image")}
+	assert.NoError(t, b.remove())
+	assert.Equal(t, 1, cli.removeCalls)
+
+	cli.removeCalls = 0
+	cli.removeErrors = []error{}
+	for i := 0; i < removeAttempts; i++ {
+		cli.removeErrors = append(cli.removeErrors, errors.New("conflict: image is being used by running container"))
+	}
+	assert.Error(t, b.remove())
+	assert.Equal(t, removeAttempts, cli.removeCalls)
+
+	cli.removeCalls = 0
+	assert.NoError(t, (&dockerBuilder{client: cli}).remove())
+	assert.Zero(t, cli.removeCalls)
+}
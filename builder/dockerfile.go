@@ -0,0 +1,151 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var dockerfileInstructions = map[string]bool{
+	"ADD":         true,
+	"ARG":         true,
+	"CMD":         true,
+	"COPY":        true,
+	"ENTRYPOINT":  true,
+	"ENV":         true,
+	"EXPOSE":      true,
+	"FROM":        true,
+	"HEALTHCHECK": true,
+	"LABEL":       true,
+	"MAINTAINER":  true,
+	"ONBUILD":     true,
+	"RUN":         true,
+	"SHELL":       true,
+	"STOPSIGNAL":  true,
+	"USER":        true,
+	"VOLUME":      true,
+	"WORKDIR":     true,
+}
+
+// heredocPattern matches the here-documents BuildKit allows in RUN, COPY and ADD such as <<EOF or <<-"EOF"
+var heredocPattern = regexp.MustCompile(`<<(-?)["']?([A-Za-z_][A-Za-z0-9_.-]*)["']?`)
+
+var heredocInstructions = map[string]bool{
+	"ADD":  true,
+	"COPY": true,
+	"RUN":  true,
+}
+
+type heredoc struct {
+	terminator string
+	// stripTabs is set for <<- which allows the terminator to be indented with tabs
+	stripTabs bool
+}
+
+// heredocs returns the here-documents started by an instruction in the order their bodies follow it
+func heredocs(instruction string) []heredoc {
+	fields := strings.Fields(instruction)
+	if len(fields) == 0 || !heredocInstructions[strings.ToUpper(fields[0])] {
+		return nil
+	}
+	out := []heredoc{}
+	for _, m := range heredocPattern.FindAllStringSubmatch(instruction, -1) {
+		out = append(out, heredoc{terminator: m[2], stripTabs: m[1] == "-"})
+	}
+	return out
+}
+
+// ValidateDockerfile performs a lightweight check of a Dockerfile's instructions without contacting the docker daemon
+func ValidateDockerfile(content string) error {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	lineNumber, instructionLine := 0, 0
+	instruction := ""
+	hasFrom := false
+	pending := []heredoc{}
+
+	validate := func() error {
+		fields := strings.Fields(instruction)
+		instruction = ""
+		if len(fields) == 0 {
+			return nil
+		}
+		keyword := strings.ToUpper(fields[0])
+		if !dockerfileInstructions[keyword] {
+			return fmt.Errorf("line %d: unknown instruction %s", instructionLine, fields[0])
+		}
+		switch {
+		case keyword == "FROM":
+			if len(fields) < 2 {
+				return fmt.Errorf("line %d: FROM requires an image", instructionLine)
+			}
+			hasFrom = true
+		case keyword != "ARG" && !hasFrom:
+			return fmt.Errorf("line %d: %s before FROM", instructionLine, keyword)
+		}
+		return nil
+	}
+
+	for scanner.Scan() {
+		lineNumber++
+		if len(pending) > 0 {
+			// skip here-document bodies up to their terminators
+			body := strings.TrimRight(scanner.Text(), "\r")
+			if pending[0].stripTabs {
+				body = strings.TrimLeft(body, "\t")
+			}
+			if body == pending[0].terminator {
+				pending = pending[1:]
+			}
+			continue
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if instruction == "" {
+			if line == "" {
+				continue
+			}
+			instructionLine = lineNumber
+		}
+		if strings.HasSuffix(line, "\\") {
+			instruction += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		instruction += line
+		pending = heredocs(instruction)
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := validate(); err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("here-document %s is not terminated", pending[0].terminator)
+	}
+
+	if !hasFrom {
+		return errors.New("no FROM instruction")
+	}
+	return nil
+}
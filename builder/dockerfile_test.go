@@ -0,0 +1,58 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDockerfile(t *testing.T) {
+	mockRemote, err := ioutil.ReadFile(filepath.Join("../testdata", "mock-remote", "Dockerfile"))
+	if assert.NoError(t, err) {
+		assert.NoError(t, ValidateDockerfile(string(mockRemote)))
+	}
+
+	var testValues = []struct {
+		dockerFile string
+		valid      bool
+	}{
+		{testDockerFile, true},
+		{"ARG VERSION=latest\nFROM alpine:${VERSION}\nrun echo test\n", true},
+		{"# comment\n\nFROM alpine AS build\nRUN apk add \\\n    # comment\n    make\nCOPY . /src\n", true},
+		{"RUN echo test\n", false},
+		{"# only a comment\n", false},
+		{"FROM\nRUN echo test\n", false},
+		{"FROM alpine\nRUNN echo test\n", false},
+		{"FROM alpine\nRUN echo \\\n", true},
+		{"FROM alpine\nRUN <<EOF\napk add make\nmake install\nEOF\nCOPY . /src\n", true},
+		{"FROM alpine\nRUN <<-\"EOF\" bash\n\tset -e\n\t# not a comment for the parser\n\tEOF\n", true},
+		{"FROM alpine\nCOPY <<one.txt <<'two.txt' /dst/\nfirst\none.txt\nsecond\ntwo.txt\nUSER nobody\n", true},
+		{"FROM alpine\nRUN <<EOF\necho test\n", false},
+		{"FROM alpine\nRUN <<EOF\necho test\nEOF\nRUNN echo test\n", false},
+	}
+
+	for _, tv := range testValues {
+		err := ValidateDockerfile(tv.dockerFile)
+		if tv.valid {
+			assert.NoError(t, err, tv.dockerFile)
+		} else {
+			assert.Error(t, err, tv.dockerFile)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"fmt"
+
+	"github.com/docker/distribution/reference"
+)
+
+// ValidateImageRef checks that ref is a valid docker image reference with an optional tag or digest
+func ValidateImageRef(ref string) error {
+	_, err := NormalizeImageRef(ref)
+	return err
+}
+
+// NormalizeImageRef returns the fully qualified form of a docker image reference, for example
+// alpine:3.12 becomes docker.io/library/alpine:3.12
+func NormalizeImageRef(ref string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %s: %w", ref, err)
+	}
+	return named.String(), nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateImageRef(t *testing.T) {
+	for ref, expected := range map[string]string{
+		"alpine":                            "docker.io/library/alpine",
+		"alpine:3.12":                       "docker.io/library/alpine:3.12",
+		"limepacker/test:latest":            "docker.io/limepacker/test:latest",
+		"registry.example.com:5000/app:1.0": "registry.example.com:5000/app:1.0",
+		"alpine@sha256:" + testDigest:       "docker.io/library/alpine@sha256:" + testDigest,
+	} {
+		assert.NoError(t, ValidateImageRef(ref), ref)
+		normalized, err := NormalizeImageRef(ref)
+		if assert.NoError(t, err, ref) {
+			assert.Equal(t, expected, normalized)
+		}
+	}
+
+	for _, ref := range []string{"", "Alpine", "limepacker/Test:latest", "alpine:", "alpine@sha256:abc", "-alpine"} {
+		assert.Error(t, ValidateImageRef(ref), ref)
+	}
+
+	b := &dockerBuilder{}
+	assert.Error(t, WithDockerTag("Limepacker/test").Apply(b))
+	assert.Empty(t, b.tags)
+}
+
+const testDigest = "c0537ff6a5218ef531ece93d4984efc99bbf3f7497c0a7726c88e2bb7584dc96"
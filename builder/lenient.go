@@ -0,0 +1,235 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const tarBlockSize = 512
+
+// extractionErrors aggregates the errors for entries skipped during lenient extraction
+type extractionErrors []error
+
+func (e extractionErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d build output entries could not be extracted: %s", len(e), strings.Join(messages, "; "))
+}
+
+// add adds the errors if err is an extractionErrors returning false otherwise
+func (e *extractionErrors) add(err error) bool {
+	skipped, ok := err.(extractionErrors)
+	if ok {
+		*e = append(*e, skipped...)
+	}
+	return ok
+}
+
+// extractArchiveLenient extracts the archive skipping entries with corrupt headers. Entries are framed from
+// the size in their headers so bodies are never scanned, after a corrupt header it scans forward block by
+// block until the next valid header.
+func (b *dockerBuilder) extractArchiveLenient(results *baseResults, archive io.Reader) error {
+	stream := &countingReader{r: archive}
+	extractor := b.extractor()
+	skipped := extractionErrors{}
+	resyncing := false
+	for {
+		start := stream.count
+		headers, size, err := readEntryHeaders(stream)
+		if err == io.EOF {
+			break
+		}
+		if err == errCorruptHeader {
+			if !resyncing {
+				offset := stream.count - tarBlockSize
+				log.Warn().Msgf("skipping unreadable build output entry at offset %d: %s", offset, err)
+				skipped = append(skipped, fmt.Errorf("entry at offset %d: %w", offset, err))
+				resyncing = true
+			}
+			continue
+		}
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				log.Warn().Msgf("skipping truncated build output entry at offset %d", start)
+				skipped = append(skipped, fmt.Errorf("entry at offset %d: %w", start, err))
+				break
+			}
+			return err
+		}
+		resyncing = false
+
+		tr := tar.NewReader(io.MultiReader(bytes.NewReader(headers), stream))
+		hdr, err := tr.Next()
+		if err != nil {
+			log.Warn().Msgf("skipping unreadable build output entry at offset %d: %s", start, err)
+			skipped = append(skipped, fmt.Errorf("entry at offset %d: %w", start, err))
+			if err := skipTo(stream, stream.count+roundToBlock(size)); err != nil {
+				break
+			}
+			continue
+		}
+		// the next header follows the data stored in the archive which differs from hdr.Size for sparse files
+		next := stream.count + roundToBlock(size)
+		if s, ok := hdr.PAXRecords["size"]; ok {
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				next = stream.count + roundToBlock(n)
+			}
+		}
+		err = extractor.extract(results, tr, hdr)
+		if err == nil {
+			err = skipTo(stream, next)
+		}
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				log.Warn().Msgf("skipping truncated build output entry %s", hdr.Name)
+				skipped = append(skipped, fmt.Errorf("entry %s: %w", hdr.Name, err))
+				break
+			}
+			return err
+		}
+	}
+
+	if len(skipped) > 0 {
+		return skipped
+	}
+	return nil
+}
+
+var errCorruptHeader = errors.New("invalid tar header")
+
+// maxMetaHeaderSize bounds the data of pax and GNU long name headers buffered ahead of an entry
+const maxMetaHeaderSize = 1 << 20
+
+// readEntryHeaders reads the header blocks of the next entry including any pax or GNU long name headers
+// preceding it returning them with the size of the entry's data. It returns io.EOF at the end of the archive
+// and errCorruptHeader after consuming a single block that isn't a valid header.
+func readEntryHeaders(r io.Reader) ([]byte, int64, error) {
+	var headers bytes.Buffer
+	for {
+		block := make([]byte, tarBlockSize)
+		if _, err := io.ReadFull(r, block); err != nil {
+			if err == io.EOF && headers.Len() > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, 0, err
+		}
+		if headers.Len() == 0 && bytes.Equal(block, zeroBlock[:]) {
+			return nil, 0, io.EOF
+		}
+		size, ok := parseHeaderBlock(block)
+		if !ok {
+			return nil, 0, errCorruptHeader
+		}
+		headers.Write(block)
+		switch block[156] {
+		case tar.TypeXHeader, tar.TypeGNULongName, tar.TypeGNULongLink:
+		default:
+			return headers.Bytes(), size, nil
+		}
+		if size > maxMetaHeaderSize {
+			return nil, 0, errCorruptHeader
+		}
+		if _, err := io.CopyN(&headers, r, roundToBlock(size)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, 0, err
+		}
+	}
+}
+
+var zeroBlock [tarBlockSize]byte
+
+// parseHeaderBlock validates the checksum of a header block returning the size of the entry's data
+func parseHeaderBlock(block []byte) (int64, bool) {
+	checksum, ok := parseOctal(block[148:156])
+	if !ok {
+		return 0, false
+	}
+	// the checksum is computed with its own field set to spaces and may have been written signed
+	var unsigned, signed int64
+	for i, c := range block {
+		if i >= 148 && i < 156 {
+			c = ' '
+		}
+		unsigned += int64(c)
+		signed += int64(int8(c))
+	}
+	if checksum != unsigned && checksum != signed {
+		return 0, false
+	}
+
+	field := block[124:136]
+	if field[0]&0x80 != 0 {
+		// base-256 encoding for sizes that don't fit in octal
+		var size int64
+		for _, c := range field[1:] {
+			size = size<<8 | int64(c)
+		}
+		return size, size >= 0
+	}
+	size, ok := parseOctal(field)
+	return size, ok && size >= 0
+}
+
+func parseOctal(field []byte) (int64, bool) {
+	s := strings.Trim(string(field), " \x00")
+	if s == "" {
+		return 0, true
+	}
+	n, err := strconv.ParseInt(s, 8, 64)
+	return n, err == nil
+}
+
+// skipTo discards the archive up to offset
+func skipTo(r *countingReader, offset int64) error {
+	if offset <= r.count {
+		return nil
+	}
+	if _, err := io.CopyN(ioutil.Discard, r, offset-r.count); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+type countingReader struct {
+	r     io.Reader
+	count int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+func roundToBlock(n int64) int64 {
+	return (n + tarBlockSize - 1) / tarBlockSize * tarBlockSize
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// buffer reads r fully into memory or, when a scratch directory is configured, into a temporary
+// file under it returning a reader positioned at the start of the buffered data
+func (b *dockerBuilder) buffer(pattern string, r io.Reader) (io.Reader, error) {
+	if b.scratchDir == "" {
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, r); err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(buf.Bytes()), nil
+	}
+
+	f, err := ioutil.TempFile(b.scratchDir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	b.scratchFiles = append(b.scratchFiles, f)
+	if _, err := io.Copy(f, r); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// cleanupScratch removes any temporary files created under the scratch directory
+func (b *dockerBuilder) cleanupScratch() {
+	for _, f := range b.scratchFiles {
+		f.Close()
+		if err := os.Remove(f.Name()); err != nil {
+			log.Warn().Msgf("error removing scratch file %s: %s", f.Name(), err)
+		}
+	}
+	b.scratchFiles = nil
+}
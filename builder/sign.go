@@ -0,0 +1,80 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+
+	"github.com/limejuice-cc/limepacker/pkg/ssl"
+)
+
+// signedRecord returns the canonical record a file's signature covers, so changing a link target, the
+// type, mode or ownership of a file invalidates its signature as well as changing its body
+func signedRecord(f File) ([]byte, error) {
+	digest, err := bodyDigest(f)
+	if err != nil {
+		return nil, err
+	}
+	kind := "regular"
+	switch {
+	case f.Mode()&os.ModeSymlink != 0:
+		kind = "symlink"
+	case f.LinkTarget() != "":
+		kind = "hardlink"
+	case f.Mode().IsDir():
+		kind = "directory"
+	}
+	record := fmt.Sprintf("name=%q\ntype=%s\nmode=%s\nlink=%q\nowner=%q:%q\nids=%d:%d\nsha256=%x\n",
+		f.Name(), kind, f.Mode(), f.LinkTarget(), f.User(), f.Group(), f.UID(), f.GID(), digest)
+	return []byte(record), nil
+}
+
+func (r *baseResults) Sign(k ssl.Key) (map[string][]byte, error) {
+	signatures := make(map[string][]byte, len(r.files))
+	for _, f := range r.files {
+		record, err := signedRecord(f)
+		if err != nil {
+			return nil, fmt.Errorf("signing %s: %w", f.Name(), err)
+		}
+		sig, err := ssl.Sign(k, record)
+		if err != nil {
+			return nil, fmt.Errorf("signing %s: %w", f.Name(), err)
+		}
+		signatures[f.Name()] = sig
+	}
+	return signatures, nil
+}
+
+func (r *baseResults) VerifySignatures(pub crypto.PublicKey, signatures map[string][]byte) error {
+	if len(signatures) != len(r.files) {
+		return fmt.Errorf("expected %d signatures but found %d", len(r.files), len(signatures))
+	}
+	for _, f := range r.files {
+		sig, ok := signatures[f.Name()]
+		if !ok {
+			return fmt.Errorf("missing signature for %s", f.Name())
+		}
+		record, err := signedRecord(f)
+		if err != nil {
+			return fmt.Errorf("verifying %s: %w", f.Name(), err)
+		}
+		if err := ssl.Verify(pub, record, sig); err != nil {
+			return fmt.Errorf("verifying %s: %w", f.Name(), err)
+		}
+	}
+	return nil
+}
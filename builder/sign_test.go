@@ -0,0 +1,70 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"os"
+	"testing"
+
+	"github.com/limejuice-cc/limepacker/manifest"
+	"github.com/limejuice-cc/limepacker/pkg/ssl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignResults(t *testing.T) {
+	key, err := ssl.GenerateKey(ssl.ECDSAKey, 256)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	results := newTestResults(
+		newTestFile(t, "bin/app", "app", 0755),
+		newTestFile(t, "etc/app.conf", "conf", 0644),
+	)
+	signatures, err := results.Sign(key)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, signatures, 2)
+	assert.NoError(t, results.VerifySignatures(key.PublicKey(), signatures))
+
+	tampered := newTestResults(
+		newTestFile(t, "bin/app", "app", 0755),
+		newTestFile(t, "etc/app.conf", "tampered", 0644),
+	)
+	assert.Error(t, tampered.VerifySignatures(key.PublicKey(), signatures))
+
+	relinked := newTestResults(
+		newTestFile(t, "bin/app", "app", 0755),
+		newTestFile(t, "etc/app.conf", "conf", 0644),
+		newLink("bin/tool", "app", "root", "root", 0, 0, os.ModeSymlink|0777, manifest.NotSpecified),
+	)
+	linkSignatures, err := relinked.Sign(key)
+	if assert.NoError(t, err) {
+		relinked.files[2] = newLink("bin/tool", "../../etc/shadow", "root", "root", 0, 0, os.ModeSymlink|0777, manifest.NotSpecified)
+		assert.Error(t, relinked.VerifySignatures(key.PublicKey(), linkSignatures))
+		relinked.files[2] = newLink("bin/tool", "app", "root", "root", 0, 0, 0755, manifest.NotSpecified)
+		assert.Error(t, relinked.VerifySignatures(key.PublicKey(), linkSignatures), "a hard link must not verify against a symlink signature")
+	}
+
+	chmoded := newTestResults(
+		newTestFile(t, "bin/app", "app", 0777),
+		newTestFile(t, "etc/app.conf", "conf", 0644),
+	)
+	assert.Error(t, chmoded.VerifySignatures(key.PublicKey(), signatures))
+
+	delete(signatures, "bin/app")
+	assert.Error(t, results.VerifySignatures(key.PublicKey(), signatures))
+}
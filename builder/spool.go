@@ -0,0 +1,153 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/limejuice-cc/limepacker/manifest"
+	"github.com/rs/zerolog/log"
+)
+
+// StreamingThreshold is the body size above which a file is spooled to a temporary file rather than held
+// in memory. Body reads a spooled file fully into memory, so large files should be read with Reader or
+// Results.Open instead. The temporary files are removed by Results.Close.
+const StreamingThreshold = 64 << 20
+
+// streamingThreshold is the threshold used by newFile, which tests lower
+var streamingThreshold int64 = StreamingThreshold
+
+// spooledFile is a file whose body is held in a temporary file
+type spooledFile struct {
+	baseFile
+	spool *os.File
+	size  int64
+}
+
+func (f *spooledFile) Body() []byte {
+	body, err := ioutil.ReadAll(f.Reader())
+	if err != nil {
+		log.Error().Msgf("cannot read spooled file %s: %s", f.name, err)
+	}
+	return body
+}
+
+func (f *spooledFile) Reader() io.Reader {
+	return io.NewSectionReader(f.spool, 0, f.size)
+}
+
+func (f *spooledFile) Size() int {
+	return int(f.size)
+}
+
+// Close closes and removes the temporary file, it is safe to close copies sharing the same file
+func (f *spooledFile) Close() error {
+	if err := f.spool.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	if err := os.Remove(f.spool.Name()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// withMode returns a copy of the file sharing its body
+func (f *spooledFile) withMode(mode os.FileMode) File {
+	out := *f
+	out.mode = mode
+	return &out
+}
+
+// newFile reads the body from r, spooling bodies larger than the streaming threshold to a temporary
+// file in dir or the default temporary directory if dir is empty
+func newFile(r io.Reader, dir, name, user, group string, uid, gid int, mode os.FileMode, fileType manifest.FileType) (File, error) {
+	var head bytes.Buffer
+	if _, err := io.Copy(&head, io.LimitReader(r, streamingThreshold+1)); err != nil {
+		return nil, err
+	}
+
+	f := baseFile{
+		name:     name,
+		user:     user,
+		group:    group,
+		uid:      uid,
+		gid:      gid,
+		mode:     mode,
+		fileType: fileType,
+	}
+	if int64(head.Len()) <= streamingThreshold {
+		f.body = head.Bytes()
+		return &f, nil
+	}
+
+	spool, err := spool(dir, io.MultiReader(&head, r))
+	if err != nil {
+		return nil, err
+	}
+	size, err := spool.Seek(0, io.SeekEnd)
+	if err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, err
+	}
+	return &spooledFile{baseFile: f, spool: spool, size: size}, nil
+}
+
+// newLink returns a symbolic or hard link to target, the mode of a symbolic link includes os.ModeSymlink
+func newLink(name, target, user, group string, uid, gid int, mode os.FileMode, fileType manifest.FileType) File {
+	return &baseFile{
+		name:       name,
+		user:       user,
+		group:      group,
+		uid:        uid,
+		gid:        gid,
+		body:       []byte{},
+		mode:       mode,
+		fileType:   fileType,
+		linkTarget: target,
+	}
+}
+
+// newDirectory returns a directory entry, its mode includes os.ModeDir
+func newDirectory(name, user, group string, uid, gid int, mode os.FileMode, fileType manifest.FileType) File {
+	return &baseFile{
+		name:     name,
+		user:     user,
+		group:    group,
+		uid:      uid,
+		gid:      gid,
+		body:     []byte{},
+		mode:     mode,
+		fileType: fileType,
+	}
+}
+
+// spool copies r to a temporary file in dir
+func spool(dir string, r io.Reader) (*os.File, error) {
+	tmp, err := ioutil.TempFile(dir, "limepacker-file-")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return tmp, nil
+}
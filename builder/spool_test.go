@@ -0,0 +1,94 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/limejuice-cc/limepacker/pkg/ssl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpooledFile(t *testing.T) {
+	defer func(threshold int64) { streamingThreshold = threshold }(streamingThreshold)
+	streamingThreshold = 16
+
+	small := newTestFile(t, "etc/small.conf", "small", 0644)
+	assert.IsType(t, &baseFile{}, small)
+
+	body := "a body larger than the streaming threshold"
+	large := newTestFile(t, "usr/lib/large.bin", body, 0666)
+	if !assert.IsType(t, &spooledFile{}, large) {
+		return
+	}
+	assert.Equal(t, len(body), large.Size())
+	assert.Equal(t, []byte(body), large.Body())
+	for i := 0; i < 2; i++ {
+		out, err := ioutil.ReadAll(large.Reader())
+		assert.NoError(t, err)
+		assert.Equal(t, body, string(out))
+	}
+
+	results := newTestResults(small, large)
+	r, err := results.Open("usr/lib/large.bin")
+	if assert.NoError(t, err) {
+		out, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, body, string(out))
+		assert.NoError(t, r.Close())
+	}
+	_, err = results.Open("missing")
+	assert.Error(t, err)
+
+	clamped := results.NormalizePermissions(PermissionPolicy{ClampWorldWritable: true})
+	if assert.IsType(t, &spooledFile{}, clamped.Files()[1]) {
+		assert.Equal(t, 0644, int(clamped.Files()[1].Mode()))
+		assert.Equal(t, 0666, int(large.Mode()))
+		assert.Equal(t, []byte(body), clamped.Files()[1].Body())
+	}
+
+	key, err := ssl.GenerateKey(ssl.ECDSAKey, 256)
+	if assert.NoError(t, err) {
+		signatures, err := results.Sign(key)
+		if assert.NoError(t, err) {
+			assert.NoError(t, results.VerifySignatures(key.PublicKey(), signatures))
+		}
+	}
+	assert.Equal(t, []FileDiff{{Name: "usr/lib/large.bin", Kind: Changed, Changes: []string{"mode"}}}, DiffResults(results, clamped))
+
+	var buf bytes.Buffer
+	assert.NoError(t, results.writeArchive(&buf))
+	defer func() {
+		name := large.(*spooledFile).spool.Name()
+		assert.NoError(t, results.Close())
+		assert.NoError(t, clamped.Close())
+		_, err := os.Stat(name)
+		assert.True(t, os.IsNotExist(err))
+	}()
+	tr := tar.NewReader(&buf)
+	for _, f := range results.Files() {
+		hdr, err := tr.Next()
+		if assert.NoError(t, err) {
+			assert.Equal(t, f.Name(), hdr.Name)
+			out, err := ioutil.ReadAll(tr)
+			assert.NoError(t, err)
+			assert.Equal(t, f.Body(), out)
+		}
+	}
+}
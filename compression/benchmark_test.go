@@ -0,0 +1,117 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+const benchConfig = `# limepacker service configuration
+[server]
+listen = "0.0.0.0:8443"
+certificate = "/etc/limepacker/tls/cert.pem"
+key = "/etc/limepacker/tls/key.pem"
+
+[storage]
+path = "/var/lib/limepacker"
+compression = "zstd"
+
+[logging]
+level = "info"
+format = "json"
+`
+
+type benchInput struct {
+	name string
+	data []byte
+}
+
+// benchCorpus returns a representative set of inputs: a small config file,
+// a large incompressible binary and a large highly redundant text file
+func benchCorpus() []benchInput {
+	binary := make([]byte, 4<<20)
+	rand.New(rand.NewSource(1)).Read(binary)
+	return []benchInput{
+		{name: "small-config", data: []byte(benchConfig)},
+		{name: "large-binary", data: binary},
+		{name: "redundant", data: bytes.Repeat([]byte("limepacker builds lime packages\n"), (4<<20)/32)},
+	}
+}
+
+func benchCompress(b *testing.B, a Algorithm, l Level, data []byte) []byte {
+	var buf bytes.Buffer
+	c, err := NewCompressor(&buf, a, WithCompressionLevel(l))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := c.Write(data); err != nil {
+		b.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkCompress(b *testing.B) {
+	corpus := benchCorpus()
+	for _, a := range SupportedAlgorithms() {
+		for _, l := range SupportedLevels() {
+			for _, input := range corpus {
+				b.Run(fmt.Sprintf("%s/%d%%/%s", a, l.Percent(), input.name), func(b *testing.B) {
+					b.SetBytes(int64(len(input.data)))
+					var compressed []byte
+					for i := 0; i < b.N; i++ {
+						compressed = benchCompress(b, a, l, input.data)
+					}
+					b.ReportMetric(float64(len(input.data))/float64(len(compressed)), "ratio")
+				})
+			}
+		}
+	}
+}
+
+func BenchmarkDecompress(b *testing.B) {
+	corpus := benchCorpus()
+	for _, a := range SupportedAlgorithms() {
+		for _, l := range SupportedLevels() {
+			for _, input := range corpus {
+				b.Run(fmt.Sprintf("%s/%d%%/%s", a, l.Percent(), input.name), func(b *testing.B) {
+					compressed := benchCompress(b, a, l, input.data)
+					b.SetBytes(int64(len(input.data)))
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						d, err := NewDecompressor(bytes.NewReader(compressed), a)
+						if err != nil {
+							b.Fatal(err)
+						}
+						if _, err := io.Copy(ioutil.Discard, d); err != nil {
+							b.Fatal(err)
+						}
+						if err := d.Close(); err != nil {
+							b.Fatal(err)
+						}
+					}
+					b.ReportMetric(float64(len(input.data))/float64(len(compressed)), "ratio")
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"io"
+)
+
+// BestCompressorFor compresses the sample with each supported algorithm at the fastest level and
+// returns a compressor over w using the algorithm which produced the smallest output
+func BestCompressorFor(sample []byte, w io.Writer) (Compressor, Algorithm, error) {
+	best, bestSize := None, -1
+	for _, a := range SupportedAlgorithms() {
+		size, err := compressedSize(sample, a)
+		if err != nil {
+			return nil, compressionAlgorithmNotSet, err
+		}
+		if bestSize < 0 || size < bestSize {
+			best, bestSize = a, size
+		}
+	}
+
+	c, err := NewCompressor(w, best)
+	if err != nil {
+		return nil, compressionAlgorithmNotSet, err
+	}
+	return c, best, nil
+}
+
+func compressedSize(sample []byte, a Algorithm) (int, error) {
+	buf := &bytes.Buffer{}
+	c, err := NewCompressor(buf, a, WithCompressionLevel(SpeedFastest))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := c.Write(sample); err != nil {
+		return 0, err
+	}
+	if err := c.Close(); err != nil {
+		return 0, err
+	}
+	return buf.Len(), nil
+}
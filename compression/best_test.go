@@ -0,0 +1,59 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBestCompressorFor(t *testing.T) {
+	sample := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 1000))
+
+	sizes := map[Algorithm]int{}
+	for _, a := range SupportedAlgorithms() {
+		size, err := compressedSize(sample, a)
+		if assert.NoError(t, err) {
+			sizes[a] = size
+		}
+	}
+
+	var buf bytes.Buffer
+	c, a, err := BestCompressorFor(sample, &buf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEqual(t, None, a)
+	assert.Equal(t, a, c.Algorithm())
+	for _, size := range sizes {
+		assert.LessOrEqual(t, sizes[a], size)
+	}
+
+	_, err = c.Write(sample)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Close())
+
+	d, err := NewDecompressor(&buf, a)
+	if assert.NoError(t, err) {
+		out, err := ioutil.ReadAll(d)
+		assert.NoError(t, err)
+		assert.Equal(t, sample, out)
+		d.Close()
+	}
+}
@@ -16,6 +16,7 @@ package compression
 
 import (
 	"errors"
+	"fmt"
 	"io"
 
 	"github.com/rs/zerolog/log"
@@ -28,6 +29,15 @@ func (c Algorithm) String() string {
 	switch c {
 	case Zstandard:
 		return "Zstandard"
+	case None:
+		return "None"
+	case Gzip:
+		return "Gzip"
+	case Xz:
+		return "xz"
+	}
+	if codec, ok := registeredCodec(c); ok {
+		return codec.Name
 	}
 	log.Panic().Msg("invalid compression algorithm")
 	return ""
@@ -38,6 +48,15 @@ func (c Algorithm) Extension() string {
 	switch c {
 	case Zstandard:
 		return "zst"
+	case None:
+		return ""
+	case Gzip:
+		return "gz"
+	case Xz:
+		return "xz"
+	}
+	if codec, ok := registeredCodec(c); ok {
+		return codec.Extension
 	}
 	log.Panic().Msg("invalid compression algorithm")
 	return ""
@@ -48,6 +67,15 @@ func (c Algorithm) MimeType() string {
 	switch c {
 	case Zstandard:
 		return "application/zstd"
+	case None:
+		return "application/octet-stream"
+	case Gzip:
+		return "application/gzip"
+	case Xz:
+		return "application/x-xz"
+	}
+	if codec, ok := registeredCodec(c); ok {
+		return codec.MimeType
 	}
 	log.Panic().Msg("invalid compression algorithm")
 	return ""
@@ -57,6 +85,12 @@ const (
 	compressionAlgorithmNotSet Algorithm = iota
 	// Zstandard uses the zstd algorithm
 	Zstandard
+	// None passes data through uncompressed
+	None
+	// Gzip uses the gzip algorithm
+	Gzip
+	// Xz uses the xz (LZMA2) algorithm
+	Xz
 	// DefaultAlgorithm is the default compression algorithm to use
 	DefaultAlgorithm = Zstandard
 )
@@ -76,13 +110,86 @@ const (
 	SpeedBestCompression
 )
 
-// AutoDetect attempts to detect the compression algorithm used
+// LevelFromPercent maps a compression percentage between 1 and 100 onto a Level returning an error for
+// percentages outside that range
+func LevelFromPercent(p int) (Level, error) {
+	switch {
+	case p < 1 || p > 100:
+		return speedNotSet, fmt.Errorf("invalid compression percentage %d", p)
+	case p <= 25:
+		return SpeedFastest, nil
+	case p <= 50:
+		return SpeedDefault, nil
+	case p <= 75:
+		return SpeedBetterCompression, nil
+	default:
+		return SpeedBestCompression, nil
+	}
+}
+
+// Percent returns the highest compression percentage which maps onto the Level
+func (l Level) Percent() int {
+	switch l {
+	case SpeedFastest:
+		return 25
+	case SpeedDefault:
+		return 50
+	case SpeedBetterCompression:
+		return 75
+	case SpeedBestCompression:
+		return 100
+	}
+	log.Panic().Msg("invalid compression level")
+	return 0
+}
+
+// AutoDetect attempts to detect the compression algorithm used. Uncompressed data is never
+// detected as None since it has no signature to match.
 func AutoDetect(r io.ReadSeeker) (Algorithm, error) {
-	if ok, err := autoDetectZstd(r); ok {
-		return Zstandard, nil
-	} else if err != nil {
-		log.Panic().Msg("unexpected error while autodetecting compression algorithm")
-		return compressionAlgorithmNotSet, errors.New("system error")
+	detectors := []struct {
+		algorithm Algorithm
+		detect    func(io.ReadSeeker) (bool, error)
+	}{
+		{Zstandard, autoDetectZstd},
+		{Gzip, autoDetectGzip},
+		{Xz, autoDetectXz},
+	}
+	for _, d := range detectors {
+		ok, err := d.detect(r)
+		if err != nil {
+			return compressionAlgorithmNotSet, fmt.Errorf("cannot autodetect algorithm: %w", err)
+		}
+		if ok {
+			return d.algorithm, nil
+		}
 	}
 	return compressionAlgorithmNotSet, errors.New("cannot autodetect algorithm")
 }
+
+// peekSignature reads up to n bytes from r and seeks back to where it started. A stream shorter than n
+// returns the bytes available rather than an error, so callers only see genuine IO errors.
+func peekSignature(r io.ReadSeeker, n int) ([]byte, error) {
+	signature := make([]byte, n)
+	l, err := io.ReadFull(r, signature)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if _, err := r.Seek(int64(-l), io.SeekCurrent); err != nil {
+		return nil, err
+	}
+	return signature[:l], nil
+}
+
+// AutoDetectAllowed attempts to detect the compression algorithm used, returning an error if it is not one of the allowed algorithms
+func AutoDetectAllowed(r io.ReadSeeker, allowed ...Algorithm) (Algorithm, error) {
+	a, err := AutoDetect(r)
+	if err != nil {
+		return compressionAlgorithmNotSet, err
+	}
+	for _, v := range allowed {
+		if a == v {
+			return a, nil
+		}
+	}
+	return compressionAlgorithmNotSet, fmt.Errorf("compression algorithm %s is not allowed", a)
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func compressTestPayload(t *testing.T, a Algorithm, payload []byte) []byte {
+	var buf bytes.Buffer
+	c, err := NewCompressor(&buf, a)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = c.Write(payload)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Close())
+	return buf.Bytes()
+}
+
+func TestAutoDetectAllowed(t *testing.T) {
+	payload := []byte("compressed payload")
+	compressed := compressTestPayload(t, Zstandard, payload)
+
+	a, err := AutoDetectAllowed(bytes.NewReader(compressed), Zstandard)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Zstandard, a)
+	}
+
+	_, err = AutoDetectAllowed(bytes.NewReader(compressed), None)
+	assert.Error(t, err)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err = gw.Write(payload)
+	assert.NoError(t, err)
+	assert.NoError(t, gw.Close())
+
+	_, err = AutoDetectAllowed(bytes.NewReader(buf.Bytes()), Zstandard)
+	assert.Error(t, err)
+}
+
+func TestLevelPercent(t *testing.T) {
+	var testValues = []struct {
+		percent int
+		level   Level
+	}{
+		{1, SpeedFastest},
+		{25, SpeedFastest},
+		{26, SpeedDefault},
+		{50, SpeedDefault},
+		{51, SpeedBetterCompression},
+		{75, SpeedBetterCompression},
+		{76, SpeedBestCompression},
+		{100, SpeedBestCompression},
+	}
+	for _, tv := range testValues {
+		l, err := LevelFromPercent(tv.percent)
+		if assert.NoError(t, err, "%d%%", tv.percent) {
+			assert.Equal(t, tv.level, l, "%d%%", tv.percent)
+		}
+	}
+
+	for _, l := range SupportedLevels() {
+		mapped, err := LevelFromPercent(l.Percent())
+		if assert.NoError(t, err) {
+			assert.Equal(t, l, mapped)
+		}
+	}
+
+	for _, p := range []int{-1, 0, 101} {
+		_, err := LevelFromPercent(p)
+		assert.Error(t, err, "%d%%", p)
+	}
+	assert.Panics(t, func() { speedNotSet.Percent() })
+}
+
+func TestAutoDecompressor(t *testing.T) {
+	payload := []byte("compressed payload")
+	for _, a := range []Algorithm{Zstandard, Gzip} {
+		d, err := AutoDecompressor(bytes.NewReader(compressTestPayload(t, a, payload)))
+		if assert.NoError(t, err) {
+			assert.Equal(t, a, d.Algorithm())
+			out, err := ioutil.ReadAll(d)
+			if assert.NoError(t, err) {
+				assert.Equal(t, payload, out)
+			}
+			assert.NoError(t, d.Close())
+		}
+	}
+
+	_, err := AutoDecompressor(bytes.NewReader([]byte("not compressed")))
+	assert.Error(t, err)
+}
+
+type failingReadSeeker struct {
+	io.ReadSeeker
+}
+
+func (f *failingReadSeeker) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestAutoDetectShortRead(t *testing.T) {
+	r := bytes.NewReader([]byte{0x1f, 0x8b})
+	assert.NotPanics(t, func() {
+		a, err := AutoDetect(r)
+		assert.Equal(t, Gzip, a)
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, 2, r.Len())
+
+	r = bytes.NewReader([]byte{0x28})
+	assert.NotPanics(t, func() {
+		a, err := AutoDetect(r)
+		assert.Equal(t, compressionAlgorithmNotSet, a)
+		assert.EqualError(t, err, "cannot autodetect algorithm")
+	})
+	assert.Equal(t, 1, r.Len())
+
+	assert.NotPanics(t, func() {
+		a, err := AutoDetect(&failingReadSeeker{ReadSeeker: bytes.NewReader(nil)})
+		assert.Equal(t, compressionAlgorithmNotSet, a)
+		assert.EqualError(t, err, "cannot autodetect algorithm: read failed")
+	})
+}
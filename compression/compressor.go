@@ -15,6 +15,8 @@
 package compression
 
 import (
+	"compress/gzip"
+	"errors"
 	"io"
 
 	"github.com/klauspost/compress/zstd"
@@ -38,7 +40,7 @@ func WithCompressionLevel(l Level) CompressorOption {
 // Apply applies the CompressionLevelOption
 func (o *compressionLevelOption) Apply(compressor interface{}) error {
 	switch v := compressor.(type) {
-	case zstdCompressor:
+	case *zstdCompressor:
 		switch o.level {
 		case SpeedFastest:
 			v.level = zstd.SpeedFastest
@@ -49,6 +51,49 @@ func (o *compressionLevelOption) Apply(compressor interface{}) error {
 		case SpeedBestCompression:
 			v.level = zstd.SpeedBestCompression
 		}
+	case *gzipCompressor:
+		switch o.level {
+		case SpeedFastest:
+			v.level = gzip.BestSpeed
+		case SpeedDefault:
+			v.level = gzip.DefaultCompression
+		case SpeedBetterCompression:
+			v.level = gzipBetterCompression
+		case SpeedBestCompression:
+			v.level = gzip.BestCompression
+		}
+	case *xzCompressor:
+		switch o.level {
+		case SpeedFastest:
+			v.preset = xzPresetFastest
+		case SpeedDefault:
+			v.preset = xzPresetDefault
+		case SpeedBetterCompression:
+			v.preset = xzPresetBetter
+		case SpeedBestCompression:
+			v.preset = xzPresetBest
+		}
+	}
+	return nil
+}
+
+type concurrencyOption struct {
+	concurrency int
+}
+
+// WithConcurrency optionally sets the number of goroutines a compressor may use, rather than GOMAXPROCS
+func WithConcurrency(n int) CompressorOption {
+	return &concurrencyOption{concurrency: n}
+}
+
+// Apply applies the concurrencyOption
+func (o *concurrencyOption) Apply(compressor interface{}) error {
+	if o.concurrency < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+	switch v := compressor.(type) {
+	case *zstdCompressor:
+		v.concurrency = o.concurrency
 	}
 	return nil
 }
@@ -56,14 +101,58 @@ func (o *compressionLevelOption) Apply(compressor interface{}) error {
 // Compressor is a generic interface for compressors
 type Compressor interface {
 	io.WriteCloser
+	// Flush writes any buffered data to the underlying writer
+	Flush() error
+	// Reset discards any unwritten data and starts a new stream to w, reusing the compressor's
+	// internal buffers. It may be called after Close.
+	Reset(w io.Writer) error
+	// Stats reports the bytes written to and by the compressor since it was opened or reset
+	Stats() Stats
 	Algorithm() Algorithm
 }
 
 // NewCompressor returns a new compressor
 func NewCompressor(w io.Writer, a Algorithm, opts ...CompressorOption) (Compressor, error) {
+	w, err := rateLimit(w, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newMinSizeCompressor(w, a, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if limited, ok := w.(*rateLimitedWriter); ok {
+		return &rateLimitedCompressor{Compressor: c, limiter: limited.limiter}, nil
+	}
+	return c, nil
+}
+
+func newMinSizeCompressor(w io.Writer, a Algorithm, opts ...CompressorOption) (Compressor, error) {
+	c := &minSizeCompressor{writer: &countingWriter{w: w}, requested: a, opts: opts}
+	for _, opt := range opts {
+		if err := opt.Apply(c); err != nil {
+			return nil, err
+		}
+	}
+	if c.minSize > 0 {
+		return c, nil
+	}
+	return newCompressor(w, a, opts...)
+}
+
+func newCompressor(w io.Writer, a Algorithm, opts ...CompressorOption) (Compressor, error) {
 	switch a {
 	case Zstandard:
 		return newZstdCompressor(w, opts...)
+	case None:
+		return newNoneCompressor(w, opts...)
+	case Gzip:
+		return newGzipCompressor(w, opts...)
+	case Xz:
+		return newXzCompressor(w, opts...)
+	}
+	if codec, ok := registeredCodec(a); ok {
+		return codec.NewCompressor(w, opts...)
 	}
 	log.Panic().Msg("unsupported compression algorithm")
 	return nil, nil
@@ -15,6 +15,8 @@
 package compression
 
 import (
+	"errors"
+	"fmt"
 	"io"
 
 	"github.com/rs/zerolog/log"
@@ -25,18 +27,110 @@ type DecompressorOption interface {
 	Apply(decompressor interface{}) error
 }
 
+type memoryLimitOption struct {
+	limit int64
+}
+
+// WithMemoryLimit optionally caps the memory a decompressor may use to decode a stream. Zstandard and xz
+// streams which need a larger window fail to decode and gzip fails if its fixed window exceeds the limit.
+func WithMemoryLimit(bytes int64) DecompressorOption {
+	return &memoryLimitOption{limit: bytes}
+}
+
+// Apply applies the memoryLimitOption
+func (o *memoryLimitOption) Apply(decompressor interface{}) error {
+	if o.limit <= 0 {
+		return errors.New("memory limit must be positive")
+	}
+	switch v := decompressor.(type) {
+	case *zstdDecompressor:
+		v.memoryLimit = o.limit
+	case *xzDecompressor:
+		v.memoryLimit = o.limit
+	case *gzipDecompressor:
+		if o.limit < gzipWindowSize+gzipReadBufferSize {
+			return fmt.Errorf("gzip requires %d bytes which exceeds the %d byte memory limit", gzipWindowSize+gzipReadBufferSize, o.limit)
+		}
+	}
+	return nil
+}
+
+type decoderConcurrencyOption struct {
+	concurrency int
+}
+
+// WithDecoderConcurrency optionally sets the number of goroutines a decompressor may use, rather than GOMAXPROCS
+func WithDecoderConcurrency(n int) DecompressorOption {
+	return &decoderConcurrencyOption{concurrency: n}
+}
+
+// Apply applies the decoderConcurrencyOption
+func (o *decoderConcurrencyOption) Apply(decompressor interface{}) error {
+	if o.concurrency < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+	switch v := decompressor.(type) {
+	case *zstdDecompressor:
+		v.concurrency = o.concurrency
+	}
+	return nil
+}
+
 // Decompressor is a generic interface for decompressors
 type Decompressor interface {
 	io.ReadCloser
 	Algorithm() Algorithm
+	// MemoryEstimate returns the approximate number of bytes the decompressor needs to decode a stream
+	MemoryEstimate() int64
 }
 
 // NewDecompressor returns a new decompressor
 func NewDecompressor(r io.Reader, a Algorithm, opts ...DecompressorOption) (Decompressor, error) {
+	limited := &maxSizeDecompressor{}
+	for _, opt := range opts {
+		if err := opt.Apply(limited); err != nil {
+			return nil, err
+		}
+	}
+	d, err := newDecompressor(r, a, opts...)
+	if err != nil || limited.maxSize == 0 {
+		return d, err
+	}
+	limited.Decompressor = d
+	return limited, nil
+}
+
+func newDecompressor(r io.Reader, a Algorithm, opts ...DecompressorOption) (Decompressor, error) {
 	switch a {
 	case Zstandard:
 		return newZstdDecompressor(r, opts...)
+	case None:
+		return newNoneDecompressor(r, opts...)
+	case Gzip:
+		return newGzipDecompressor(r, opts...)
+	case Xz:
+		return newXzDecompressor(r, opts...)
+	}
+	if codec, ok := registeredCodec(a); ok {
+		return codec.NewDecompressor(r, opts...)
 	}
 	log.Panic().Msg("unsupported compression algorithm")
 	return nil, nil
 }
+
+// AutoDecompressor detects the compression algorithm of r and returns a decompressor for it
+// which reads the full stream from the start. Algorithm reports the detected algorithm.
+func AutoDecompressor(r io.ReadSeeker, opts ...DecompressorOption) (Decompressor, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	a, err := AutoDetect(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return NewDecompressor(r, a, opts...)
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var zstdDictionaryMagic = []byte{0x37, 0xa4, 0x30, 0xec}
+
+type dictionaryOption struct {
+	dict []byte
+}
+
+// WithDictionary optionally compresses small inputs with a trained zstd dictionary. The option may also be
+// passed to NewDecompressor, which selects the dictionary by the ID recorded in the stream.
+func WithDictionary(dict []byte) CompressorOption {
+	return &dictionaryOption{dict: dict}
+}
+
+// Apply applies the dictionaryOption
+func (o *dictionaryOption) Apply(v interface{}) error {
+	if _, err := DictionaryID(o.dict); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case *zstdCompressor:
+		v.dictionary = o.dict
+	case *zstdDecompressor:
+		v.dictionaries = append(v.dictionaries, o.dict)
+	}
+	return nil
+}
+
+// DictionaryID returns the ID of a trained zstd dictionary
+func DictionaryID(dict []byte) (uint32, error) {
+	if len(dict) < 8 || !bytes.Equal(dict[:4], zstdDictionaryMagic) {
+		return 0, errors.New("not a zstd dictionary")
+	}
+	return binary.LittleEndian.Uint32(dict[4:8]), nil
+}
+
+// StreamDictionaryID returns the ID of the dictionary a zstd stream was compressed with, or 0 if it
+// was compressed without one. The position of r is left unchanged.
+func StreamDictionaryID(r io.ReadSeeker) (uint32, error) {
+	header := make([]byte, zstd.HeaderMaxSize)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return 0, err
+	}
+	if _, err := r.Seek(int64(-n), io.SeekCurrent); err != nil {
+		return 0, err
+	}
+	var h zstd.Header
+	if err := h.Decode(header[:n]); err != nil {
+		return 0, err
+	}
+	return h.DictionaryID, nil
+}
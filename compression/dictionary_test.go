@@ -0,0 +1,119 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dictionarySamples returns small, similar config files such as those the test dictionary was trained on
+func dictionarySamples(n int) [][]byte {
+	samples := make([][]byte, n)
+	for i := range samples {
+		samples[i] = []byte(fmt.Sprintf(`[Unit]
+Description=Limepacker managed service %d
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+User=service%d
+ExecStart=/usr/local/bin/service%d --config /etc/service%d/config.yaml --listen 0.0.0.0:%d
+Restart=on-failure
+RestartSec=%d
+LimitNOFILE=65536
+
+[Install]
+WantedBy=multi-user.target
+`, i, i, i, i, 8000+i, 1+i%10))
+	}
+	return samples
+}
+
+func compressedSamplesSize(t *testing.T, samples [][]byte, opts ...CompressorOption) int {
+	total := 0
+	for _, sample := range samples {
+		var buf bytes.Buffer
+		c, err := NewCompressor(&buf, Zstandard, opts...)
+		if !assert.NoError(t, err) {
+			return 0
+		}
+		_, err = c.Write(sample)
+		assert.NoError(t, err)
+		assert.NoError(t, c.Close())
+		total += buf.Len()
+
+		var decompressorOpts []DecompressorOption
+		for _, opt := range opts {
+			decompressorOpts = append(decompressorOpts, opt)
+		}
+		d, err := NewDecompressor(bytes.NewReader(buf.Bytes()), Zstandard, decompressorOpts...)
+		if assert.NoError(t, err) {
+			out, err := ioutil.ReadAll(d)
+			assert.NoError(t, err)
+			assert.Equal(t, sample, out)
+			assert.NoError(t, d.Close())
+		}
+	}
+	return total
+}
+
+func TestDictionary(t *testing.T) {
+	dict, err := ioutil.ReadFile(filepath.Join("../testdata", "zstd-config.dict"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	id, err := DictionaryID(dict)
+	assert.NoError(t, err)
+	assert.NotZero(t, id)
+
+	// the dictionary was trained with zstd --train on the first 100 samples
+	samples := dictionarySamples(120)[100:]
+	plain := compressedSamplesSize(t, samples)
+	withDictionary := compressedSamplesSize(t, samples, WithDictionary(dict))
+	assert.Less(t, withDictionary*2, plain)
+
+	var buf bytes.Buffer
+	c, err := NewCompressor(&buf, Zstandard, WithDictionary(dict))
+	if assert.NoError(t, err) {
+		_, err = c.Write(samples[0])
+		assert.NoError(t, err)
+		assert.NoError(t, c.Close())
+
+		r := bytes.NewReader(buf.Bytes())
+		streamID, err := StreamDictionaryID(r)
+		assert.NoError(t, err)
+		assert.Equal(t, id, streamID)
+		assert.Equal(t, int64(buf.Len()), int64(r.Len()))
+
+		d, err := NewDecompressor(r, Zstandard)
+		if assert.NoError(t, err) {
+			_, err = ioutil.ReadAll(d)
+			assert.Error(t, err)
+			assert.NoError(t, d.Close())
+		}
+	}
+
+	_, err = NewCompressor(&buf, Zstandard, WithDictionary([]byte("not a dictionary")))
+	assert.Error(t, err)
+	_, err = DictionaryID(nil)
+	assert.Error(t, err)
+}
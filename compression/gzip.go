@@ -0,0 +1,163 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+const (
+	gzipBetterCompression = 7
+	// gzipWindowSize is the deflate history window
+	gzipWindowSize = 32 << 10
+	// gzipReadBufferSize is the size of the buffered reader wrapping the stream
+	gzipReadBufferSize = 4 << 10
+)
+
+type gzipCompressor struct {
+	writer  *gzip.Writer
+	level   int
+	closed  bool
+	output  *countingWriter
+	bytesIn int64
+}
+
+func (g *gzipCompressor) Algorithm() Algorithm {
+	return Gzip
+}
+
+func (g *gzipCompressor) Write(p []byte) (int, error) {
+	if g.closed {
+		return 0, errors.New("compressor is not open")
+	}
+	n, err := g.writer.Write(p)
+	g.bytesIn += int64(n)
+	return n, err
+}
+
+func (g *gzipCompressor) Flush() error {
+	if g.closed {
+		return errors.New("compressor is not open")
+	}
+	return g.writer.Flush()
+}
+
+func (g *gzipCompressor) Close() error {
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+	return g.writer.Close()
+}
+
+// Reset reuses the writer to write a new stream to w
+func (g *gzipCompressor) Reset(w io.Writer) error {
+	g.output = &countingWriter{w: w}
+	g.bytesIn = 0
+	g.writer.Reset(g.output)
+	g.closed = false
+	return nil
+}
+
+func (g *gzipCompressor) Stats() Stats {
+	return Stats{BytesIn: g.bytesIn, BytesOut: g.output.written}
+}
+
+func newGzipCompressor(w io.Writer, opts ...CompressorOption) (Compressor, error) {
+	c := &gzipCompressor{
+		level:  gzip.BestCompression,
+		output: &countingWriter{w: w},
+	}
+
+	for _, opt := range opts {
+		if err := opt.Apply(c); err != nil {
+			return nil, err
+		}
+	}
+
+	writer, err := gzip.NewWriterLevel(c.output, c.level)
+	if err != nil {
+		return nil, err
+	}
+	c.writer = writer
+
+	return c, nil
+}
+
+// gzipDecompressor reads concatenated gzip members (as written by tools such as pigz) as a
+// single stream since multistream mode is on by default
+type gzipDecompressor struct {
+	reader *gzip.Reader
+}
+
+func (g *gzipDecompressor) Read(p []byte) (int, error) {
+	if g.reader == nil {
+		return 0, errors.New("decompressor is not open")
+	}
+	return g.reader.Read(p)
+}
+
+func (g *gzipDecompressor) Close() error {
+	if g.reader == nil {
+		return nil
+	}
+	defer func() {
+		g.reader = nil
+	}()
+	return g.reader.Close()
+}
+
+func (g *gzipDecompressor) Algorithm() Algorithm {
+	return Gzip
+}
+
+// MemoryEstimate returns the size of the deflate window plus the read buffer
+func (g *gzipDecompressor) MemoryEstimate() int64 {
+	return gzipWindowSize + gzipReadBufferSize
+}
+
+func newGzipDecompressor(r io.Reader, opts ...DecompressorOption) (Decompressor, error) {
+	d := &gzipDecompressor{}
+
+	for _, opt := range opts {
+		if err := opt.Apply(d); err != nil {
+			return nil, err
+		}
+	}
+
+	reader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	reader.Multistream(true)
+	d.reader = reader
+
+	return d, nil
+}
+
+const (
+	gzipMagic0 byte = 0x1f
+	gzipMagic1 byte = 0x8b
+)
+
+func autoDetectGzip(r io.ReadSeeker) (bool, error) {
+	signature, err := peekSignature(r, 2)
+	if err != nil || len(signature) < 2 {
+		return false, err
+	}
+	return signature[0] == gzipMagic0 && signature[1] == gzipMagic1, nil
+}
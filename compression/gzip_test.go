@@ -0,0 +1,91 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGzipRoundTrip(t *testing.T) {
+	assert.Equal(t, "Gzip", Gzip.String())
+	assert.Equal(t, "gz", Gzip.Extension())
+	assert.Equal(t, "application/gzip", Gzip.MimeType())
+
+	payload := bytes.Repeat([]byte("gzip payload "), 1024)
+	for _, level := range SupportedLevels() {
+		var buf bytes.Buffer
+		c, err := NewCompressor(&buf, Gzip, WithCompressionLevel(level))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, Gzip, c.Algorithm())
+		_, err = c.Write(payload)
+		assert.NoError(t, err)
+		assert.NoError(t, c.Close())
+
+		d, err := NewDecompressor(&buf, Gzip)
+		if assert.NoError(t, err) {
+			assert.Equal(t, Gzip, d.Algorithm())
+			out, err := ioutil.ReadAll(d)
+			if assert.NoError(t, err) {
+				assert.Equal(t, payload, out)
+			}
+			assert.NoError(t, d.Close())
+		}
+	}
+
+	c := &gzipCompressor{}
+	assert.NoError(t, WithCompressionLevel(SpeedFastest).Apply(c))
+	assert.Equal(t, gzip.BestSpeed, c.level)
+	assert.NoError(t, WithCompressionLevel(SpeedBestCompression).Apply(c))
+	assert.Equal(t, gzip.BestCompression, c.level)
+
+	compressed := compressTestPayload(t, Gzip, payload)
+	_, err := NewDecompressor(bytes.NewReader(compressed), Gzip, WithMemoryLimit(1024))
+	assert.Error(t, err)
+	d, err := NewDecompressor(bytes.NewReader(compressed), Gzip, WithMemoryLimit(1<<20))
+	if assert.NoError(t, err) {
+		assert.NoError(t, d.Close())
+	}
+}
+
+func TestGzipMultistream(t *testing.T) {
+	first := compressTestPayload(t, Gzip, []byte("first member "))
+	second := compressTestPayload(t, Gzip, []byte("second member"))
+
+	d, err := NewDecompressor(bytes.NewReader(append(first, second...)), Gzip)
+	if assert.NoError(t, err) {
+		out, err := ioutil.ReadAll(d)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []byte("first member second member"), out)
+		}
+		assert.NoError(t, d.Close())
+	}
+}
+
+func TestGzipAutoDetect(t *testing.T) {
+	compressed := compressTestPayload(t, Gzip, []byte("compressed payload"))
+	r := bytes.NewReader(compressed)
+	a, err := AutoDetect(r)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Gzip, a)
+		assert.Equal(t, int64(len(compressed)), int64(r.Len()), "auto detection should not consume input")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrMaxDecompressedSize is returned when a stream decompresses to more than the configured maximum size
+var ErrMaxDecompressedSize = errors.New("decompressed size exceeds the maximum")
+
+// maxSizeDecompressor fails reads once more than maxSize bytes have been decompressed to guard against
+// decompression bombs
+type maxSizeDecompressor struct {
+	Decompressor
+	maxSize  int64
+	read     int64
+	exceeded bool
+}
+
+// Read returns the bytes up to the limit along with ErrMaxDecompressedSize on the read which passes it,
+// later reads fail without reading from the underlying decompressor
+func (d *maxSizeDecompressor) Read(p []byte) (int, error) {
+	if d.exceeded {
+		return 0, d.limitError()
+	}
+	n, err := d.Decompressor.Read(p)
+	d.read += int64(n)
+	if d.read <= d.maxSize {
+		return n, err
+	}
+	d.exceeded = true
+	n -= int(d.read - d.maxSize)
+	if n < 0 {
+		n = 0
+	}
+	if err != nil && err != io.EOF {
+		return n, err
+	}
+	return n, d.limitError()
+}
+
+func (d *maxSizeDecompressor) limitError() error {
+	return fmt.Errorf("%w of %d bytes", ErrMaxDecompressedSize, d.maxSize)
+}
+
+type maxDecompressedSizeOption struct {
+	size int64
+}
+
+// WithMaxDecompressedSize optionally fails reads once a stream has decompressed to more than size bytes
+func WithMaxDecompressedSize(size int64) DecompressorOption {
+	return &maxDecompressedSizeOption{size: size}
+}
+
+// Apply applies the maxDecompressedSizeOption
+func (o *maxDecompressedSizeOption) Apply(decompressor interface{}) error {
+	if o.size <= 0 {
+		return errors.New("maximum decompressed size must be positive")
+	}
+	switch v := decompressor.(type) {
+	case *maxSizeDecompressor:
+		v.maxSize = o.size
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxDecompressedSize(t *testing.T) {
+	payload := bytes.Repeat([]byte{0}, 1<<20)
+	for _, a := range []Algorithm{Zstandard, Gzip, None} {
+		var buf bytes.Buffer
+		c, err := NewCompressor(&buf, a)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		_, err = c.Write(payload)
+		assert.NoError(t, err)
+		assert.NoError(t, c.Close())
+
+		d, err := NewDecompressor(bytes.NewReader(buf.Bytes()), a, WithMaxDecompressedSize(1024))
+		if assert.NoError(t, err) {
+			out, err := ioutil.ReadAll(d)
+			assert.True(t, errors.Is(err, ErrMaxDecompressedSize), a.String())
+			assert.Len(t, out, 1024)
+			assert.NoError(t, d.Close())
+		}
+
+		d, err = NewDecompressor(bytes.NewReader(buf.Bytes()), a, WithMaxDecompressedSize(int64(len(payload))))
+		if assert.NoError(t, err) {
+			out, err := ioutil.ReadAll(d)
+			assert.NoError(t, err)
+			assert.Equal(t, payload, out)
+			assert.Equal(t, a, d.Algorithm())
+			assert.NoError(t, d.Close())
+		}
+	}
+
+	_, err := NewDecompressor(bytes.NewReader(nil), Zstandard, WithMaxDecompressedSize(0))
+	assert.Error(t, err)
+}
+
+type countingReadDecompressor struct {
+	Decompressor
+	reads int
+}
+
+func (c *countingReadDecompressor) Read(p []byte) (int, error) {
+	c.reads++
+	return c.Decompressor.Read(p)
+}
+
+func TestMaxDecompressedSizeAfterLimit(t *testing.T) {
+	underlying := &countingReadDecompressor{Decompressor: &noneDecompressor{reader: bytes.NewReader(make([]byte, 64))}}
+	d := &maxSizeDecompressor{Decompressor: underlying, maxSize: 10}
+
+	p := make([]byte, 16)
+	n, err := d.Read(p)
+	assert.Equal(t, 10, n)
+	assert.True(t, errors.Is(err, ErrMaxDecompressedSize))
+
+	for i := 0; i < 2; i++ {
+		n, err = d.Read(p)
+		assert.Zero(t, n)
+		assert.True(t, errors.Is(err, ErrMaxDecompressedSize))
+	}
+	assert.Equal(t, 1, underlying.reads)
+}
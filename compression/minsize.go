@@ -0,0 +1,149 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// minSizeCompressor buffers input until minSize bytes have been written before opening the requested
+// compressor. Input which is smaller in total is stored uncompressed.
+type minSizeCompressor struct {
+	writer     *countingWriter
+	requested  Algorithm
+	opts       []CompressorOption
+	minSize    int
+	buffer     bytes.Buffer
+	compressor Compressor
+	started    bool
+	used       Algorithm
+	closed     bool
+	bytesIn    int64
+}
+
+// Algorithm returns the algorithm actually used, which is only known to be None once the compressor
+// has been closed without reaching the minimum size
+func (c *minSizeCompressor) Algorithm() Algorithm {
+	if c.used != compressionAlgorithmNotSet {
+		return c.used
+	}
+	return c.requested
+}
+
+func (c *minSizeCompressor) Write(p []byte) (int, error) {
+	if c.closed {
+		return 0, errors.New("compressor is not open")
+	}
+	if c.started {
+		n, err := c.compressor.Write(p)
+		c.bytesIn += int64(n)
+		return n, err
+	}
+	c.bytesIn += int64(len(p))
+	c.buffer.Write(p)
+	if c.buffer.Len() < c.minSize {
+		return len(p), nil
+	}
+	if err := c.start(); err != nil {
+		return 0, err
+	}
+	if _, err := c.compressor.Write(c.buffer.Bytes()); err != nil {
+		return 0, err
+	}
+	c.buffer.Reset()
+	return len(p), nil
+}
+
+// Flush flushes the underlying compressor, data below the minimum size remains buffered
+// until more is written or the compressor is closed
+func (c *minSizeCompressor) Flush() error {
+	if c.closed {
+		return errors.New("compressor is not open")
+	}
+	if c.started {
+		return c.compressor.Flush()
+	}
+	return nil
+}
+
+func (c *minSizeCompressor) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.started {
+		return c.compressor.Close()
+	}
+	c.used = None
+	_, err := c.writer.Write(c.buffer.Bytes())
+	c.buffer.Reset()
+	return err
+}
+
+// Reset starts a new stream to w, the requested compressor is reused once the minimum size is reached again
+func (c *minSizeCompressor) Reset(w io.Writer) error {
+	c.writer = &countingWriter{w: w}
+	c.bytesIn = 0
+	c.buffer.Reset()
+	c.started = false
+	c.used = compressionAlgorithmNotSet
+	c.closed = false
+	return nil
+}
+
+// Stats counts input which is still buffered below the minimum size as written
+func (c *minSizeCompressor) Stats() Stats {
+	return Stats{BytesIn: c.bytesIn, BytesOut: c.writer.written}
+}
+
+// start opens the requested compressor, or resets the one used by a previous stream
+func (c *minSizeCompressor) start() error {
+	if c.compressor == nil {
+		compressor, err := newCompressor(c.writer, c.requested, c.opts...)
+		if err != nil {
+			return err
+		}
+		c.compressor = compressor
+	} else if err := c.compressor.Reset(c.writer); err != nil {
+		return err
+	}
+	c.started = true
+	c.used = c.compressor.Algorithm()
+	return nil
+}
+
+type minCompressSizeOption struct {
+	size int
+}
+
+// WithMinCompressSize stores input smaller than n bytes in total uncompressed, the compressor's
+// Algorithm reports which codec was used once it has been closed
+func WithMinCompressSize(n int) CompressorOption {
+	return &minCompressSizeOption{size: n}
+}
+
+// Apply applies the minCompressSizeOption
+func (o *minCompressSizeOption) Apply(compressor interface{}) error {
+	switch v := compressor.(type) {
+	case *minSizeCompressor:
+		if o.size < 0 {
+			return errors.New("minimum compress size cannot be negative")
+		}
+		v.minSize = o.size
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinCompressSize(t *testing.T) {
+	small := []byte("0123456789")
+	var buf bytes.Buffer
+	c, err := NewCompressor(&buf, Zstandard, WithMinCompressSize(512))
+	if assert.NoError(t, err) {
+		_, err := c.Write(small)
+		assert.NoError(t, err)
+		assert.NoError(t, c.Close())
+		assert.Equal(t, None, c.Algorithm())
+		assert.Equal(t, small, buf.Bytes())
+	}
+
+	large := bytes.Repeat([]byte("0123456789"), 100)
+	buf.Reset()
+	c, err = NewCompressor(&buf, Zstandard, WithMinCompressSize(512))
+	if assert.NoError(t, err) {
+		for i := 0; i < len(large); i += 100 {
+			_, err := c.Write(large[i : i+100])
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, c.Close())
+		assert.Equal(t, Zstandard, c.Algorithm())
+		assert.Less(t, buf.Len(), len(large))
+
+		d, err := NewDecompressor(&buf, c.Algorithm())
+		if assert.NoError(t, err) {
+			out, err := ioutil.ReadAll(d)
+			if assert.NoError(t, err) {
+				assert.Equal(t, large, out)
+			}
+		}
+
+		buf.Reset()
+		assert.NoError(t, c.Reset(&buf))
+		_, err = c.Write(small)
+		assert.NoError(t, err)
+		assert.NoError(t, c.Close())
+		assert.Equal(t, None, c.Algorithm())
+		assert.Equal(t, small, buf.Bytes())
+	}
+
+	_, err = NewCompressor(&buf, Zstandard, WithMinCompressSize(-1))
+	assert.Error(t, err)
+}
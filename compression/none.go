@@ -0,0 +1,108 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"errors"
+	"io"
+)
+
+type noneCompressor struct {
+	writer  io.Writer
+	written int64
+}
+
+func (n *noneCompressor) Algorithm() Algorithm {
+	return None
+}
+
+func (n *noneCompressor) Write(p []byte) (int, error) {
+	if n.writer == nil {
+		return 0, errors.New("compressor is not open")
+	}
+	written, err := n.writer.Write(p)
+	n.written += int64(written)
+	return written, err
+}
+
+func (n *noneCompressor) Flush() error {
+	if n.writer == nil {
+		return errors.New("compressor is not open")
+	}
+	return nil
+}
+
+func (n *noneCompressor) Close() error {
+	n.writer = nil
+	return nil
+}
+
+func (n *noneCompressor) Reset(w io.Writer) error {
+	n.writer = w
+	n.written = 0
+	return nil
+}
+
+func (n *noneCompressor) Stats() Stats {
+	return Stats{BytesIn: n.written, BytesOut: n.written}
+}
+
+func newNoneCompressor(w io.Writer, opts ...CompressorOption) (Compressor, error) {
+	c := &noneCompressor{writer: w}
+
+	for _, opt := range opts {
+		if err := opt.Apply(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+type noneDecompressor struct {
+	reader io.Reader
+}
+
+func (n *noneDecompressor) Read(p []byte) (int, error) {
+	if n.reader == nil {
+		return 0, errors.New("decompressor is not open")
+	}
+	return n.reader.Read(p)
+}
+
+func (n *noneDecompressor) Close() error {
+	n.reader = nil
+	return nil
+}
+
+func (n *noneDecompressor) Algorithm() Algorithm {
+	return None
+}
+
+func (n *noneDecompressor) MemoryEstimate() int64 {
+	return 0
+}
+
+func newNoneDecompressor(r io.Reader, opts ...DecompressorOption) (Decompressor, error) {
+	d := &noneDecompressor{reader: r}
+
+	for _, opt := range opts {
+		if err := opt.Apply(d); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoneRoundTrip(t *testing.T) {
+	assert.Equal(t, "None", None.String())
+	assert.Equal(t, "", None.Extension())
+	assert.Equal(t, "application/octet-stream", None.MimeType())
+
+	payload := []byte("uncompressed payload")
+	var buf bytes.Buffer
+	c, err := NewCompressor(&buf, None, WithCompressionLevel(SpeedFastest))
+	if assert.NoError(t, err) {
+		assert.Equal(t, None, c.Algorithm())
+		_, err := c.Write(payload)
+		assert.NoError(t, err)
+		assert.NoError(t, c.Flush())
+		assert.NoError(t, c.Close())
+		_, err = c.Write(payload)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, payload, buf.Bytes())
+
+	d, err := NewDecompressor(&buf, None)
+	if assert.NoError(t, err) {
+		assert.Equal(t, None, d.Algorithm())
+		out, err := ioutil.ReadAll(d)
+		if assert.NoError(t, err) {
+			assert.Equal(t, payload, out)
+		}
+		assert.NoError(t, d.Close())
+	}
+
+	_, err = AutoDetect(bytes.NewReader(payload))
+	assert.Error(t, err)
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitedWriter throttles writes to the underlying writer using a token bucket
+type rateLimitedWriter struct {
+	w       io.Writer
+	limiter *rate.Limiter
+}
+
+func (w *rateLimitedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if burst := w.limiter.Burst(); n > burst {
+			n = burst
+		}
+		if err := w.limiter.WaitN(context.Background(), n); err != nil {
+			return written, err
+		}
+		m, err := w.w.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// rateLimitedCompressor keeps writes rate limited when the compressor is reset to a new writer
+type rateLimitedCompressor struct {
+	Compressor
+	limiter *rate.Limiter
+}
+
+func (c *rateLimitedCompressor) Reset(w io.Writer) error {
+	return c.Compressor.Reset(&rateLimitedWriter{w: w, limiter: c.limiter})
+}
+
+// rateLimit wraps w in a rate limited writer when a rate limit option is specified
+func rateLimit(w io.Writer, opts ...CompressorOption) (io.Writer, error) {
+	limited := &rateLimitedWriter{w: w}
+	for _, opt := range opts {
+		if err := opt.Apply(limited); err != nil {
+			return nil, err
+		}
+	}
+	if limited.limiter == nil {
+		return w, nil
+	}
+	return limited, nil
+}
+
+type rateLimitOption struct {
+	bytesPerSec int64
+}
+
+// WithRateLimit throttles the compressed bytes written to the underlying writer to bytesPerSec
+func WithRateLimit(bytesPerSec int64) CompressorOption {
+	return &rateLimitOption{bytesPerSec: bytesPerSec}
+}
+
+// Apply applies the rateLimitOption
+func (o *rateLimitOption) Apply(compressor interface{}) error {
+	switch v := compressor.(type) {
+	case *rateLimitedWriter:
+		if o.bytesPerSec <= 0 {
+			return errors.New("rate limit must be greater than zero")
+		}
+		v.limiter = rate.NewLimiter(rate.Limit(o.bytesPerSec), int(o.bytesPerSec))
+	}
+	return nil
+}
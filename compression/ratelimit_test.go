@@ -0,0 +1,55 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	const limit = 64 * 1024
+	payload := make([]byte, 2*limit)
+
+	var buf bytes.Buffer
+	c, err := NewCompressor(&buf, None, WithRateLimit(limit))
+	if !assert.NoError(t, err) {
+		return
+	}
+	start := time.Now()
+	n, err := c.Write(payload)
+	elapsed := time.Since(start)
+	assert.NoError(t, err)
+	assert.Equal(t, len(payload), n)
+	assert.NoError(t, c.Close())
+	assert.Equal(t, payload, buf.Bytes())
+
+	// the bucket starts full so only the bytes beyond the first second's burst are throttled
+	assert.GreaterOrEqual(t, int64(elapsed), int64(900*time.Millisecond))
+
+	var reset bytes.Buffer
+	assert.NoError(t, c.Reset(&reset))
+	start = time.Now()
+	_, err = c.Write(payload[:limit])
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(900*time.Millisecond))
+	assert.Equal(t, payload[:limit], reset.Bytes())
+
+	_, err = NewCompressor(&buf, None, WithRateLimit(0))
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Codec describes a compression algorithm registered at runtime
+type Codec struct {
+	Name            string
+	Extension       string
+	MimeType        string
+	NewCompressor   func(w io.Writer, opts ...CompressorOption) (Compressor, error)
+	NewDecompressor func(r io.Reader, opts ...DecompressorOption) (Decompressor, error)
+}
+
+var (
+	builtinAlgorithms = []Algorithm{Zstandard, None, Gzip, Xz}
+
+	registryLock sync.RWMutex
+	registry     = map[Algorithm]*Codec{}
+)
+
+func isBuiltin(a Algorithm) bool {
+	for _, b := range builtinAlgorithms {
+		if a == b {
+			return true
+		}
+	}
+	return false
+}
+
+// Register registers a codec for an algorithm not built into the package
+func Register(a Algorithm, c *Codec) error {
+	if a == compressionAlgorithmNotSet || isBuiltin(a) {
+		return fmt.Errorf("cannot register built in algorithm %d", a)
+	}
+	if c == nil || c.Name == "" || c.NewCompressor == nil || c.NewDecompressor == nil {
+		return errors.New("codec must specify a name, a compressor and a decompressor")
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := registry[a]; ok {
+		return fmt.Errorf("algorithm %d is already registered", a)
+	}
+	registry[a] = c
+	return nil
+}
+
+func registeredCodec(a Algorithm) (*Codec, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	c, ok := registry[a]
+	return c, ok
+}
+
+// SupportedAlgorithms returns the built in and registered compression algorithms
+func SupportedAlgorithms() []Algorithm {
+	out := append([]Algorithm{}, builtinAlgorithms...)
+
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	registered := make([]Algorithm, 0, len(registry))
+	for a := range registry {
+		registered = append(registered, a)
+	}
+	sort.Slice(registered, func(i, j int) bool { return registered[i] < registered[j] })
+
+	return append(out, registered...)
+}
+
+// SupportedLevels returns the supported compression levels
+func SupportedLevels() []Level {
+	return []Level{SpeedFastest, SpeedDefault, SpeedBetterCompression, SpeedBestCompression}
+}
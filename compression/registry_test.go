@@ -0,0 +1,106 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testAlgorithm Algorithm = 1000
+
+type testCompressor struct {
+	io.Writer
+}
+
+func (c *testCompressor) Flush() error         { return nil }
+func (c *testCompressor) Close() error         { return nil }
+func (c *testCompressor) Algorithm() Algorithm { return testAlgorithm }
+
+func (c *testCompressor) Stats() Stats { return Stats{} }
+
+func (c *testCompressor) Reset(w io.Writer) error {
+	c.Writer = w
+	return nil
+}
+
+type testDecompressor struct {
+	io.Reader
+}
+
+func (d *testDecompressor) Close() error          { return nil }
+func (d *testDecompressor) Algorithm() Algorithm  { return testAlgorithm }
+func (d *testDecompressor) MemoryEstimate() int64 { return 0 }
+
+var testCodec = &Codec{
+	Name:      "test",
+	Extension: "test",
+	MimeType:  "application/x-test",
+	NewCompressor: func(w io.Writer, opts ...CompressorOption) (Compressor, error) {
+		return &testCompressor{Writer: w}, nil
+	},
+	NewDecompressor: func(r io.Reader, opts ...DecompressorOption) (Decompressor, error) {
+		return &testDecompressor{Reader: r}, nil
+	},
+}
+
+// unregister removes a codec registered by a test so the registry is unchanged for later runs
+func unregister(a Algorithm) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	delete(registry, a)
+}
+
+func TestRegistry(t *testing.T) {
+	assert.Contains(t, SupportedAlgorithms(), Zstandard)
+	assert.NotContains(t, SupportedAlgorithms(), testAlgorithm)
+	assert.Equal(t, []Level{SpeedFastest, SpeedDefault, SpeedBetterCompression, SpeedBestCompression}, SupportedLevels())
+
+	assert.Error(t, Register(Zstandard, testCodec))
+	assert.Error(t, Register(compressionAlgorithmNotSet, testCodec))
+	assert.Error(t, Register(testAlgorithm+1, &Codec{Name: "incomplete"}))
+
+	if assert.NoError(t, Register(testAlgorithm, testCodec)) {
+		t.Cleanup(func() { unregister(testAlgorithm) })
+		assert.Contains(t, SupportedAlgorithms(), Zstandard)
+		assert.Contains(t, SupportedAlgorithms(), testAlgorithm)
+		assert.Error(t, Register(testAlgorithm, testCodec))
+
+		assert.Equal(t, "test", testAlgorithm.String())
+		assert.Equal(t, "test", testAlgorithm.Extension())
+		assert.Equal(t, "application/x-test", testAlgorithm.MimeType())
+
+		var buf bytes.Buffer
+		c, err := NewCompressor(&buf, testAlgorithm)
+		if assert.NoError(t, err) {
+			_, err := c.Write([]byte("test"))
+			assert.NoError(t, err)
+			assert.NoError(t, c.Close())
+		}
+		d, err := NewDecompressor(&buf, testAlgorithm)
+		if assert.NoError(t, err) {
+			out, err := ioutil.ReadAll(d)
+			if assert.NoError(t, err) {
+				assert.Equal(t, []byte("test"), out)
+			}
+		}
+	}
+
+	assert.Panics(t, func() { _ = Algorithm(1001).String() })
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import "io"
+
+// Stats reports how much data a compressor has consumed and produced
+type Stats struct {
+	// BytesIn is the number of uncompressed bytes written to the compressor
+	BytesIn int64
+	// BytesOut is the number of compressed bytes written to the underlying writer
+	BytesOut int64
+}
+
+// Ratio returns the compression ratio BytesIn / BytesOut, or 0 if no output has been written
+func (s Stats) Ratio() float64 {
+	if s.BytesOut == 0 {
+		return 0
+	}
+	return float64(s.BytesIn) / float64(s.BytesOut)
+}
+
+// countingWriter counts the bytes written to the underlying writer
+type countingWriter struct {
+	w       io.Writer
+	written int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.written += int64(n)
+	return n, err
+}
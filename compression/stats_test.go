@@ -0,0 +1,55 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	assert.Zero(t, Stats{}.Ratio())
+	assert.Equal(t, 4.0, Stats{BytesIn: 400, BytesOut: 100}.Ratio())
+
+	payload := bytes.Repeat([]byte("0123456789"), 1000)
+	for _, a := range []Algorithm{Zstandard, Gzip, None} {
+		for _, opts := range [][]CompressorOption{nil, {WithMinCompressSize(512)}} {
+			var buf bytes.Buffer
+			c, err := NewCompressor(&buf, a, opts...)
+			if !assert.NoError(t, err) {
+				continue
+			}
+			_, err = c.Write(payload)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(len(payload)), c.Stats().BytesIn)
+			assert.NoError(t, c.Close())
+
+			stats := c.Stats()
+			assert.Equal(t, int64(len(payload)), stats.BytesIn, a.String())
+			assert.Equal(t, int64(buf.Len()), stats.BytesOut, a.String())
+			if a == None {
+				assert.Equal(t, 1.0, stats.Ratio())
+			} else {
+				assert.Greater(t, stats.Ratio(), 10.0)
+			}
+
+			buf.Reset()
+			assert.NoError(t, c.Reset(&buf))
+			assert.Equal(t, Stats{}, c.Stats())
+		}
+	}
+}
@@ -0,0 +1,429 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// xzPresetDictCap holds the dictionary capacity of the xz presets 0 through 9, which is the setting
+// the pure Go encoder supports
+var xzPresetDictCap = [...]int{
+	256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20,
+}
+
+const (
+	xzPresetFastest = 0
+	xzPresetDefault = 6
+	xzPresetBetter  = 8
+	xzPresetBest    = 9
+)
+
+type xzCompressor struct {
+	writer  *xz.Writer
+	preset  int
+	closed  bool
+	output  *countingWriter
+	bytesIn int64
+}
+
+func (x *xzCompressor) Algorithm() Algorithm {
+	return Xz
+}
+
+func (x *xzCompressor) Write(p []byte) (int, error) {
+	if x.closed {
+		return 0, errors.New("compressor is not open")
+	}
+	n, err := x.writer.Write(p)
+	x.bytesIn += int64(n)
+	return n, err
+}
+
+// Flush is not supported since the xz encoder can only complete its block when it is closed
+func (x *xzCompressor) Flush() error {
+	if x.closed {
+		return errors.New("compressor is not open")
+	}
+	return errors.New("xz compressor does not support flush")
+}
+
+func (x *xzCompressor) Close() error {
+	if x.closed {
+		return nil
+	}
+	x.closed = true
+	return x.writer.Close()
+}
+
+// Reset opens a new encoder writing to w since the xz encoder cannot be reused
+func (x *xzCompressor) Reset(w io.Writer) error {
+	x.output = &countingWriter{w: w}
+	x.bytesIn = 0
+	writer, err := xz.WriterConfig{DictCap: xzPresetDictCap[x.preset]}.NewWriter(x.output)
+	if err != nil {
+		return err
+	}
+	x.writer = writer
+	x.closed = false
+	return nil
+}
+
+func (x *xzCompressor) Stats() Stats {
+	return Stats{BytesIn: x.bytesIn, BytesOut: x.output.written}
+}
+
+func newXzCompressor(w io.Writer, opts ...CompressorOption) (Compressor, error) {
+	c := &xzCompressor{
+		preset: xzPresetDefault,
+	}
+
+	for _, opt := range opts {
+		if err := opt.Apply(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.Reset(w); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// xzDecompressor reads concatenated xz streams as a single stream
+type xzDecompressor struct {
+	reader      *xz.Reader
+	memoryLimit int64
+	dictCap     int64
+}
+
+func (x *xzDecompressor) Read(p []byte) (int, error) {
+	if x.reader == nil {
+		return 0, errors.New("decompressor is not open")
+	}
+	return x.reader.Read(p)
+}
+
+func (x *xzDecompressor) Close() error {
+	x.reader = nil
+	return nil
+}
+
+func (x *xzDecompressor) Algorithm() Algorithm {
+	return Xz
+}
+
+// MemoryEstimate returns the largest dictionary size declared by the blocks read so far, starting with the
+// first block, which the decoder allocates
+func (x *xzDecompressor) MemoryEstimate() int64 {
+	return x.dictCap
+}
+
+// checkDictCap records the dictionary size of a block returning an error if it exceeds the memory limit
+func (x *xzDecompressor) checkDictCap(dictCap int64) error {
+	if x.memoryLimit > 0 && dictCap > x.memoryLimit {
+		return fmt.Errorf("stream requires a %d byte dictionary which exceeds the %d byte memory limit", dictCap, x.memoryLimit)
+	}
+	if dictCap > x.dictCap {
+		x.dictCap = dictCap
+	}
+	return nil
+}
+
+func newXzDecompressor(r io.Reader, opts ...DecompressorOption) (Decompressor, error) {
+	d := &xzDecompressor{}
+
+	for _, opt := range opts {
+		if err := opt.Apply(d); err != nil {
+			return nil, err
+		}
+	}
+
+	br := bufio.NewReaderSize(r, xzStreamHeaderSize+xzMaxBlockHeaderSize)
+	header, _ := br.Peek(xzStreamHeaderSize + xzMaxBlockHeaderSize)
+	if len(header) > xzStreamHeaderSize {
+		if err := d.checkDictCap(xzDictCap(header[xzStreamHeaderSize:])); err != nil {
+			return nil, err
+		}
+	}
+
+	reader, err := xz.NewReader(&xzBlockGuard{r: br, d: d})
+	if err != nil {
+		return nil, err
+	}
+	d.reader = reader
+
+	return d, nil
+}
+
+const (
+	xzStreamHeaderSize   = 12
+	xzStreamFooterSize   = 12
+	xzMaxBlockHeaderSize = 1024
+	xzLZMA2FilterID      = 0x21
+)
+
+// xzCheckSize maps the check type in the stream flags onto the size of the check following each block
+var xzCheckSize = [...]int64{0, 4, 4, 4, 8, 8, 8, 16, 16, 16, 32, 32, 32, 64, 64, 64}
+
+type xzGuardState int
+
+const (
+	xzGuardStream xzGuardState = iota
+	xzGuardBlock
+	xzGuardChunk
+	xzGuardIndex
+	xzGuardIndexRecords
+)
+
+// xzBlockGuard follows the framing of concatenated xz streams as the decoder reads them so the dictionary
+// size declared by every block header is checked before the decoder sees the header and allocates it
+type xzBlockGuard struct {
+	r *bufio.Reader
+	d *xzDecompressor
+
+	state xzGuardState
+	// pending holds framing bytes which have been parsed but not yet returned
+	pending []byte
+	// remaining is the number of bytes to pass through before the next framing element
+	remaining int64
+
+	checkSize int64
+	blockSize int64
+	indexSize int64
+	vlisLeft  uint64
+}
+
+func (g *xzBlockGuard) Read(p []byte) (int, error) {
+	for len(g.pending) == 0 && g.remaining == 0 {
+		if err := g.next(); err != nil {
+			return 0, err
+		}
+	}
+	if len(g.pending) > 0 {
+		n := copy(p, g.pending)
+		g.pending = g.pending[n:]
+		return n, nil
+	}
+	if int64(len(p)) > g.remaining {
+		p = p[:g.remaining]
+	}
+	n, err := g.r.Read(p)
+	g.remaining -= int64(n)
+	return n, err
+}
+
+// read reads the next n framing bytes into pending
+func (g *xzBlockGuard) read(n int) ([]byte, error) {
+	start := len(g.pending)
+	g.pending = append(g.pending, make([]byte, n)...)
+	if _, err := io.ReadFull(g.r, g.pending[start:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return g.pending[start:], nil
+}
+
+func (g *xzBlockGuard) readVLI() (uint64, error) {
+	var v uint64
+	for i := 0; i < 9; i++ {
+		b, err := g.read(1)
+		if err != nil {
+			return 0, err
+		}
+		v |= uint64(b[0]&0x7F) << (7 * i)
+		if b[0]&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("xz: invalid variable length integer")
+}
+
+// next parses the next framing element
+func (g *xzBlockGuard) next() error {
+	switch g.state {
+	case xzGuardStream:
+		if _, err := g.r.Peek(1); err == io.EOF {
+			return io.EOF
+		}
+		header, err := g.read(4)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(header, []byte{0, 0, 0, 0}) {
+			// stream padding
+			return nil
+		}
+		if header, err = g.read(xzStreamHeaderSize - 4); err != nil {
+			return err
+		}
+		g.checkSize = xzCheckSize[header[3]&0x0F]
+		g.state = xzGuardBlock
+	case xzGuardBlock:
+		size, err := g.read(1)
+		if err != nil {
+			return err
+		}
+		if size[0] == 0 {
+			g.indexSize = 1
+			g.state = xzGuardIndex
+			return nil
+		}
+		if _, err := g.read((int(size[0])+1)*4 - 1); err != nil {
+			return err
+		}
+		if err := g.d.checkDictCap(xzDictCap(g.pending)); err != nil {
+			return err
+		}
+		g.blockSize = 0
+		g.state = xzGuardChunk
+	case xzGuardChunk:
+		control, err := g.read(1)
+		if err != nil {
+			return err
+		}
+		switch c := control[0]; {
+		case c == 0:
+			// end of block followed by the padding and check
+			g.blockSize++
+			g.remaining = (4-g.blockSize%4)%4 + g.checkSize
+			g.state = xzGuardBlock
+		case c == 1 || c == 2:
+			size, err := g.read(2)
+			if err != nil {
+				return err
+			}
+			g.remaining = int64(size[0])<<8 | int64(size[1]) + 1
+			g.blockSize += 3 + g.remaining
+		case c >= 0x80:
+			headerSize := 4
+			if c >= 0xC0 {
+				// the chunk resets the properties
+				headerSize++
+			}
+			sizes, err := g.read(headerSize)
+			if err != nil {
+				return err
+			}
+			g.remaining = int64(sizes[2])<<8 | int64(sizes[3]) + 1
+			g.blockSize += 1 + int64(headerSize) + g.remaining
+		default:
+			return fmt.Errorf("xz: invalid LZMA2 chunk control byte %#x", c)
+		}
+	case xzGuardIndex:
+		start := len(g.pending)
+		records, err := g.readVLI()
+		if err != nil {
+			return err
+		}
+		if records > math.MaxUint64/2 {
+			return errors.New("xz: invalid index")
+		}
+		g.indexSize += int64(len(g.pending) - start)
+		g.vlisLeft = 2 * records
+		g.state = xzGuardIndexRecords
+	case xzGuardIndexRecords:
+		if g.vlisLeft == 0 {
+			// index padding and CRC32 followed by the stream footer
+			g.remaining = (4-g.indexSize%4)%4 + 4 + xzStreamFooterSize
+			g.state = xzGuardStream
+			return nil
+		}
+		start := len(g.pending)
+		if _, err := g.readVLI(); err != nil {
+			return err
+		}
+		g.indexSize += int64(len(g.pending) - start)
+		g.vlisLeft--
+	}
+	return nil
+}
+
+// xzDictCap returns the LZMA2 dictionary size declared by a block header, or 0 if the header cannot be
+// parsed
+func xzDictCap(block []byte) int64 {
+	if len(block) == 0 || block[0] == 0 {
+		return 0
+	}
+	size := (int(block[0]) + 1) * 4
+	if len(block) < size {
+		return 0
+	}
+	block = block[:size]
+	flags, pos := block[1], 2
+
+	readVLI := func() (uint64, bool) {
+		var v uint64
+		for i := 0; i < 9 && pos < len(block); i++ {
+			b := block[pos]
+			pos++
+			v |= uint64(b&0x7F) << (7 * i)
+			if b&0x80 == 0 {
+				return v, true
+			}
+		}
+		return 0, false
+	}
+
+	// skip the optional compressed and uncompressed sizes
+	for _, flag := range []byte{0x40, 0x80} {
+		if flags&flag == 0 {
+			continue
+		}
+		if _, ok := readVLI(); !ok {
+			return 0
+		}
+	}
+	for i := 0; i <= int(flags&0x03); i++ {
+		id, ok := readVLI()
+		if !ok {
+			return 0
+		}
+		propsSize, ok := readVLI()
+		if !ok || pos+int(propsSize) > len(block) {
+			return 0
+		}
+		if id == xzLZMA2FilterID && propsSize == 1 {
+			dictCap, err := lzma.DecodeDictCap(block[pos])
+			if err != nil {
+				return 0
+			}
+			return dictCap
+		}
+		pos += int(propsSize)
+	}
+	return 0
+}
+
+var xzMagic = []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}
+
+func autoDetectXz(r io.ReadSeeker) (bool, error) {
+	signature, err := peekSignature(r, len(xzMagic))
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(signature, xzMagic), nil
+}
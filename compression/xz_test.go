@@ -0,0 +1,143 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ulikunitz/xz"
+)
+
+func TestXzRoundTrip(t *testing.T) {
+	assert.Equal(t, "xz", Xz.String())
+	assert.Equal(t, "xz", Xz.Extension())
+	assert.Equal(t, "application/x-xz", Xz.MimeType())
+
+	payload := bytes.Repeat([]byte("xz payload "), 1024)
+	for _, level := range SupportedLevels() {
+		var buf bytes.Buffer
+		c, err := NewCompressor(&buf, Xz, WithCompressionLevel(level))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, Xz, c.Algorithm())
+		_, err = c.Write(payload)
+		assert.NoError(t, err)
+		assert.Error(t, c.Flush(), "xz cannot flush a partial block")
+		assert.NoError(t, c.Close())
+		assert.Less(t, buf.Len(), len(payload))
+
+		d, err := NewDecompressor(&buf, Xz)
+		if assert.NoError(t, err) {
+			assert.Equal(t, Xz, d.Algorithm())
+			out, err := ioutil.ReadAll(d)
+			if assert.NoError(t, err) {
+				assert.Equal(t, payload, out)
+			}
+			assert.NoError(t, d.Close())
+		}
+	}
+
+	c := &xzCompressor{}
+	assert.NoError(t, WithCompressionLevel(SpeedFastest).Apply(c))
+	assert.Equal(t, xzPresetFastest, c.preset)
+	assert.NoError(t, WithCompressionLevel(SpeedBestCompression).Apply(c))
+	assert.Equal(t, xzPresetBest, c.preset)
+}
+
+func TestXzAutoDetect(t *testing.T) {
+	compressed := compressTestPayload(t, Xz, []byte("compressed payload"))
+	r := bytes.NewReader(compressed)
+	a, err := AutoDetect(r)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Xz, a)
+		assert.Equal(t, int64(len(compressed)), int64(r.Len()), "auto detection should not consume input")
+	}
+}
+
+func TestXzMemoryLimit(t *testing.T) {
+	payload := bytes.Repeat([]byte("xz payload "), 1024)
+	for _, tv := range []struct {
+		level   Level
+		dictCap int
+	}{
+		{SpeedFastest, xzPresetDictCap[xzPresetFastest]},
+		{SpeedBestCompression, xzPresetDictCap[xzPresetBest]},
+	} {
+		var buf bytes.Buffer
+		c, err := NewCompressor(&buf, Xz, WithCompressionLevel(tv.level))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		_, err = c.Write(payload)
+		assert.NoError(t, err)
+		assert.NoError(t, c.Close())
+
+		d, err := NewDecompressor(bytes.NewReader(buf.Bytes()), Xz)
+		if assert.NoError(t, err) {
+			assert.Equal(t, int64(tv.dictCap), d.MemoryEstimate())
+			assert.NoError(t, d.Close())
+		}
+
+		_, err = NewDecompressor(bytes.NewReader(buf.Bytes()), Xz, WithMemoryLimit(int64(tv.dictCap-1)))
+		assert.Error(t, err)
+
+		d, err = NewDecompressor(bytes.NewReader(buf.Bytes()), Xz, WithMemoryLimit(int64(tv.dictCap)))
+		if assert.NoError(t, err) {
+			out, err := ioutil.ReadAll(d)
+			assert.NoError(t, err)
+			assert.Equal(t, payload, out)
+		}
+	}
+}
+
+func TestXzMemoryLimitConcatenated(t *testing.T) {
+	payload := bytes.Repeat([]byte("xz payload "), 1024)
+	var buf bytes.Buffer
+	for i, dictCap := range []int{64 << 10, 8 << 20} {
+		if i > 0 {
+			// stream padding between the streams
+			buf.Write([]byte{0, 0, 0, 0})
+		}
+		w, err := xz.WriterConfig{DictCap: dictCap}.NewWriter(&buf)
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, err = w.Write(payload)
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+	}
+
+	d, err := NewDecompressor(bytes.NewReader(buf.Bytes()), Xz)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(64<<10), d.MemoryEstimate())
+		out, err := ioutil.ReadAll(d)
+		assert.NoError(t, err)
+		assert.Equal(t, append(append([]byte{}, payload...), payload...), out)
+		assert.Equal(t, int64(8<<20), d.MemoryEstimate())
+	}
+
+	// the first stream fits within the limit but the second must still be rejected
+	d, err = NewDecompressor(bytes.NewReader(buf.Bytes()), Xz, WithMemoryLimit(1<<20))
+	if assert.NoError(t, err) {
+		_, err = ioutil.ReadAll(d)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "exceeds the 1048576 byte memory limit")
+		}
+	}
+}
@@ -17,15 +17,21 @@ package compression
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
-	"os"
+	"runtime"
 
 	"github.com/klauspost/compress/zstd"
 )
 
 type zstdCompressor struct {
-	encoder *zstd.Encoder
-	level   zstd.EncoderLevel
+	encoder     *zstd.Encoder
+	level       zstd.EncoderLevel
+	concurrency int
+	dictionary  []byte
+	closed      bool
+	output      *countingWriter
+	bytesIn     int64
 }
 
 func (z *zstdCompressor) Algorithm() Algorithm {
@@ -33,25 +39,46 @@ func (z *zstdCompressor) Algorithm() Algorithm {
 }
 
 func (z *zstdCompressor) Write(p []byte) (int, error) {
-	if z.encoder == nil {
+	if z.closed {
 		return 0, errors.New("compressor is not open")
 	}
-	return z.encoder.Write(p)
+	n, err := z.encoder.Write(p)
+	z.bytesIn += int64(n)
+	return n, err
+}
+
+func (z *zstdCompressor) Flush() error {
+	if z.closed {
+		return errors.New("compressor is not open")
+	}
+	return z.encoder.Flush()
 }
 
 func (z *zstdCompressor) Close() error {
-	if z.encoder == nil {
+	if z.closed {
 		return nil
 	}
-	defer func() {
-		z.encoder = nil
-	}()
+	z.closed = true
 	return z.encoder.Close()
 }
 
+// Reset reuses the encoder and its buffers to write a new stream to w
+func (z *zstdCompressor) Reset(w io.Writer) error {
+	z.output = &countingWriter{w: w}
+	z.bytesIn = 0
+	z.encoder.Reset(z.output)
+	z.closed = false
+	return nil
+}
+
+func (z *zstdCompressor) Stats() Stats {
+	return Stats{BytesIn: z.bytesIn, BytesOut: z.output.written}
+}
+
 func newZstdCompressor(w io.Writer, opts ...CompressorOption) (Compressor, error) {
 	c := &zstdCompressor{
-		level: zstd.SpeedBestCompression,
+		level:  zstd.SpeedBestCompression,
+		output: &countingWriter{w: w},
 	}
 
 	for _, opt := range opts {
@@ -60,7 +87,15 @@ func newZstdCompressor(w io.Writer, opts ...CompressorOption) (Compressor, error
 		}
 	}
 
-	enc, err := zstd.NewWriter(w, zstd.WithEncoderLevel(c.level))
+	encoderOptions := []zstd.EOption{zstd.WithEncoderLevel(c.level)}
+	if c.concurrency > 0 {
+		encoderOptions = append(encoderOptions, zstd.WithEncoderConcurrency(c.concurrency))
+	}
+	if c.dictionary != nil {
+		encoderOptions = append(encoderOptions, zstd.WithEncoderDict(c.dictionary))
+	}
+
+	enc, err := zstd.NewWriter(c.output, encoderOptions...)
 	if err != nil {
 		return nil, err
 	}
@@ -70,14 +105,21 @@ func newZstdCompressor(w io.Writer, opts ...CompressorOption) (Compressor, error
 }
 
 type zstdDecompressor struct {
-	decoder *zstd.Decoder
+	decoder      *zstd.Decoder
+	memoryLimit  int64
+	concurrency  int
+	dictionaries [][]byte
 }
 
 func (z *zstdDecompressor) Read(p []byte) (int, error) {
 	if z.decoder == nil {
 		return 0, errors.New("decompressor is not open")
 	}
-	return z.decoder.Read(p)
+	n, err := z.decoder.Read(p)
+	if errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return n, fmt.Errorf("stream requires more than the %d byte memory limit: %w", z.memoryLimit, err)
+	}
+	return n, err
 }
 
 func (z *zstdDecompressor) Close() error {
@@ -95,14 +137,57 @@ func (z *zstdDecompressor) Algorithm() Algorithm {
 	return Zstandard
 }
 
+// MemoryEstimate returns the window the decoder will allocate, which is capped by the memory limit, plus a
+// block buffer for each concurrent block decoder
+func (z *zstdDecompressor) MemoryEstimate() int64 {
+	window := int64(zstdDefaultWindowSize)
+	if z.memoryLimit > 0 && z.memoryLimit < window {
+		window = z.memoryLimit
+	}
+	concurrency := z.concurrency
+	if concurrency == 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	return window + int64(concurrency)*zstdBlockBufferSize
+}
+
 func newZstdDecompressor(r io.Reader, opts ...DecompressorOption) (Decompressor, error) {
-	dec, err := zstd.NewReader(r)
+	d := &zstdDecompressor{}
+
+	for _, opt := range opts {
+		if err := opt.Apply(d); err != nil {
+			return nil, err
+		}
+	}
+
+	decoderOptions := []zstd.DOption{}
+	if d.memoryLimit > 0 {
+		decoderOptions = append(decoderOptions, zstd.WithDecoderMaxMemory(uint64(d.memoryLimit)))
+	}
+	if d.concurrency > 0 {
+		decoderOptions = append(decoderOptions, zstd.WithDecoderConcurrency(d.concurrency))
+	}
+	if len(d.dictionaries) > 0 {
+		decoderOptions = append(decoderOptions, zstd.WithDecoderDicts(d.dictionaries...))
+	}
+
+	dec, err := zstd.NewReader(r, decoderOptions...)
 	if err != nil {
 		return nil, err
 	}
-	return &zstdDecompressor{decoder: dec}, nil
+	d.decoder = dec
+
+	return d, nil
 }
 
+const (
+	// zstdDefaultWindowSize is the window used by the encoder's default level and the largest window
+	// decoders are recommended to support
+	zstdDefaultWindowSize = 8 << 20
+	// zstdBlockBufferSize is the largest compressed block a block decoder buffers
+	zstdBlockBufferSize = 128 << 10
+)
+
 const (
 	zstdMagic          uint32 = 0xFD2FB528
 	zstdMagicSkipStart uint32 = 0x184D2A50
@@ -110,11 +195,8 @@ const (
 )
 
 func autoDetectZstd(r io.ReadSeeker) (bool, error) {
-	signature := make([]byte, 4)
-	if l, err := r.Read(signature); err != nil || l < 4 {
-		return false, err
-	}
-	if _, err := r.Seek(-4, os.SEEK_CUR); err != nil {
+	signature, err := peekSignature(r, 4)
+	if err != nil || len(signature) < 4 {
 		return false, err
 	}
 	prefix := binary.LittleEndian.Uint32(signature)
@@ -0,0 +1,180 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZstdFlush(t *testing.T) {
+	var buf bytes.Buffer
+	c, err := NewCompressor(&buf, Zstandard)
+	if !assert.NoError(t, err) {
+		return
+	}
+	message := []byte("first message")
+	_, err = c.Write(message)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Flush())
+
+	d, err := NewDecompressor(bytes.NewReader(buf.Bytes()), Zstandard)
+	if assert.NoError(t, err) {
+		partial := make([]byte, len(message))
+		_, err := io.ReadFull(d, partial)
+		if assert.NoError(t, err) {
+			assert.Equal(t, message, partial)
+		}
+		assert.NoError(t, d.Close())
+	}
+
+	assert.NoError(t, c.Close())
+	assert.Error(t, c.Flush())
+}
+
+func TestZstdMemoryLimit(t *testing.T) {
+	var buf bytes.Buffer
+	c, err := NewCompressor(&buf, Zstandard)
+	if !assert.NoError(t, err) {
+		return
+	}
+	payload := bytes.Repeat([]byte("limepacker "), 64*1024)
+	_, err = c.Write(payload)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Close())
+
+	d, err := NewDecompressor(bytes.NewReader(buf.Bytes()), Zstandard, WithMemoryLimit(1024))
+	if assert.NoError(t, err) {
+		_, err := ioutil.ReadAll(d)
+		assert.Error(t, err)
+		assert.NoError(t, d.Close())
+	}
+
+	d, err = NewDecompressor(bytes.NewReader(buf.Bytes()), Zstandard, WithMemoryLimit(64*1024*1024))
+	if assert.NoError(t, err) {
+		out, err := ioutil.ReadAll(d)
+		if assert.NoError(t, err) {
+			assert.Equal(t, payload, out)
+		}
+		assert.NoError(t, d.Close())
+	}
+
+	_, err = NewDecompressor(bytes.NewReader(buf.Bytes()), Zstandard, WithMemoryLimit(0))
+	assert.Error(t, err)
+}
+
+func TestZstdCompressionLevel(t *testing.T) {
+	var testValues = []struct {
+		level    Level
+		expected zstd.EncoderLevel
+	}{
+		{SpeedFastest, zstd.SpeedFastest},
+		{SpeedDefault, zstd.SpeedDefault},
+		{SpeedBetterCompression, zstd.SpeedBetterCompression},
+		{SpeedBestCompression, zstd.SpeedBestCompression},
+	}
+
+	for _, tv := range testValues {
+		var buf bytes.Buffer
+		c, err := NewCompressor(&buf, Zstandard, WithCompressionLevel(tv.level))
+		if assert.NoError(t, err) {
+			assert.Equal(t, tv.expected, c.(*zstdCompressor).level)
+			assert.NoError(t, c.Close())
+		}
+	}
+}
+
+func TestZstdMemoryEstimate(t *testing.T) {
+	d, err := NewDecompressor(bytes.NewReader(nil), Zstandard)
+	if assert.NoError(t, err) {
+		estimate := d.MemoryEstimate()
+		assert.GreaterOrEqual(t, estimate, int64(zstdDefaultWindowSize))
+		assert.Less(t, estimate, int64(1<<30))
+		assert.NoError(t, d.Close())
+	}
+
+	d, err = NewDecompressor(bytes.NewReader(nil), Zstandard, WithMemoryLimit(1<<20))
+	if assert.NoError(t, err) {
+		assert.Less(t, d.MemoryEstimate(), int64(zstdDefaultWindowSize))
+		assert.Greater(t, d.MemoryEstimate(), int64(1<<20))
+		assert.NoError(t, d.Close())
+	}
+
+	d, err = NewDecompressor(bytes.NewReader(nil), None)
+	if assert.NoError(t, err) {
+		assert.Zero(t, d.MemoryEstimate())
+	}
+}
+
+func TestZstdConcurrency(t *testing.T) {
+	_, err := NewCompressor(&bytes.Buffer{}, Zstandard, WithConcurrency(0))
+	assert.Error(t, err)
+	_, err = NewDecompressor(bytes.NewReader(nil), Zstandard, WithDecoderConcurrency(0))
+	assert.Error(t, err)
+
+	var buf bytes.Buffer
+	c, err := NewCompressor(&buf, Zstandard, WithConcurrency(2))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, c.(*zstdCompressor).concurrency)
+		_, err = c.Write([]byte("test data"))
+		assert.NoError(t, err)
+		assert.NoError(t, c.Close())
+	}
+
+	d, err := NewDecompressor(&buf, Zstandard, WithDecoderConcurrency(1))
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(zstdDefaultWindowSize+zstdBlockBufferSize), d.MemoryEstimate())
+		out, err := ioutil.ReadAll(d)
+		assert.NoError(t, err)
+		assert.Equal(t, "test data", string(out))
+		assert.NoError(t, d.Close())
+	}
+}
+
+func TestZstdReset(t *testing.T) {
+	var first, second bytes.Buffer
+	c, err := NewCompressor(&first, Zstandard)
+	if !assert.NoError(t, err) {
+		return
+	}
+	encoder := c.(*zstdCompressor).encoder
+
+	_, err = c.Write([]byte("first message"))
+	assert.NoError(t, err)
+	assert.NoError(t, c.Close())
+	_, err = c.Write([]byte("closed"))
+	assert.Error(t, err)
+
+	assert.NoError(t, c.Reset(&second))
+	assert.Same(t, encoder, c.(*zstdCompressor).encoder)
+	_, err = c.Write([]byte("second message"))
+	assert.NoError(t, err)
+	assert.NoError(t, c.Close())
+
+	for expected, buf := range map[string]*bytes.Buffer{"first message": &first, "second message": &second} {
+		d, err := NewDecompressor(buf, Zstandard)
+		if assert.NoError(t, err) {
+			out, err := ioutil.ReadAll(d)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, string(out))
+			assert.NoError(t, d.Close())
+		}
+	}
+}
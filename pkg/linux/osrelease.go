@@ -74,6 +74,7 @@ func ParseDistributionID(id string) Distribution {
 // OSRelease represents system information https://www.freedesktop.org/software/systemd/man/os-release.html
 type OSRelease struct {
 	ID              Distribution
+	IDLike          []string
 	Name            string
 	PrettyName      string
 	Version         string
@@ -81,9 +82,36 @@ type OSRelease struct {
 	Extra           map[string]string
 }
 
+// unquoteOSReleaseValue removes the outer quotes of a value, unescaping the \$, \", \\ and \` escapes the
+// os-release format allows in double quoted values
+func unquoteOSReleaseValue(kv *keyvalue.Pair) error {
+	if len(kv.Value) < 2 {
+		return nil
+	}
+	first, last := kv.Value[0], kv.Value[len(kv.Value)-1]
+	if first == '\'' && last == '\'' {
+		kv.Value = kv.Value[1 : len(kv.Value)-1]
+		return nil
+	}
+	if first != '"' || last != '"' {
+		return nil
+	}
+
+	quoted := kv.Value[1 : len(kv.Value)-1]
+	var sb strings.Builder
+	for i := 0; i < len(quoted); i++ {
+		if quoted[i] == '\\' && i+1 < len(quoted) && strings.IndexByte("$\"\\`", quoted[i+1]) >= 0 {
+			i++
+		}
+		sb.WriteByte(quoted[i])
+	}
+	kv.Value = sb.String()
+	return nil
+}
+
 // ParseOSRelease parses an os-release find
 func ParseOSRelease(in string) (*OSRelease, error) {
-	pairs, err := keyvalue.ParsePairSlice(in, keyvalue.RemoveOuterQuotes)
+	pairs, err := keyvalue.ParsePairSlice(in, unquoteOSReleaseValue)
 	if err != nil {
 		return nil, err
 	}
@@ -96,6 +124,8 @@ func ParseOSRelease(in string) (*OSRelease, error) {
 		switch key {
 		case "ID":
 			out.ID = ParseDistributionID(value)
+		case "ID_LIKE":
+			out.IDLike = strings.Fields(value)
 		case "NAME":
 			out.Name = value
 		case "PRETTY_NAME":
@@ -111,6 +141,32 @@ func ParseOSRelease(in string) (*OSRelease, error) {
 	return out, nil
 }
 
+// EffectiveDistribution returns the distribution if recognized, otherwise the first recognized
+// distribution in ID_LIKE, falling back to GenericLinux
+func (o *OSRelease) EffectiveDistribution() Distribution {
+	if o.ID != GenericLinux && o.ID != noDistributionSet {
+		return o.ID
+	}
+	for _, id := range o.IDLike {
+		if d := ParseDistributionID(id); d != GenericLinux {
+			return d
+		}
+	}
+	return GenericLinux
+}
+
+// ToBuildArgs returns the distribution details as docker build args
+func (o *OSRelease) ToBuildArgs() map[string]*string {
+	id := o.ID.String()
+	version := o.Version
+	codename := o.VersionCodename
+	return map[string]*string{
+		"DISTRO_ID":       &id,
+		"DISTRO_VERSION":  &version,
+		"DISTRO_CODENAME": &codename,
+	}
+}
+
 func (o *OSRelease) String() string {
 	extra := []string{}
 	for k, v := range o.Extra {
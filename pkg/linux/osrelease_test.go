@@ -48,3 +48,52 @@ func TestParseOSRelease(t *testing.T) {
 		assert.Equal(t, "https://www.ubuntu.com/", v.Extra["HOME_URL"])
 	}
 }
+
+func TestParseOSReleaseEscapes(t *testing.T) {
+	v, err := ParseOSRelease("NAME=\"A \\\"quoted\\\" name\"\n" +
+		"PRETTY_NAME=\"costs \\$5 \\\\ \\`uname\\` \\n\"\n" +
+		"VARIANT='single \\\"quoted\\\"'\n" +
+		"VERSION_ID=\"\"\n")
+	if assert.NoError(t, err) {
+		assert.Equal(t, `A "quoted" name`, v.Name)
+		assert.Equal(t, "costs $5 \\ `uname` \\n", v.PrettyName)
+		assert.Equal(t, `single \"quoted\"`, v.Extra["VARIANT"])
+		assert.Empty(t, v.Version)
+	}
+}
+
+func TestOSReleaseToBuildArgs(t *testing.T) {
+	v, err := ParseOSRelease(osReleaseTest)
+	if assert.NoError(t, err) {
+		args := v.ToBuildArgs()
+		assert.Len(t, args, 3)
+		if assert.Contains(t, args, "DISTRO_ID") {
+			assert.Equal(t, "ubuntu", *args["DISTRO_ID"])
+		}
+		if assert.Contains(t, args, "DISTRO_VERSION") {
+			assert.Equal(t, "20.04", *args["DISTRO_VERSION"])
+		}
+		if assert.Contains(t, args, "DISTRO_CODENAME") {
+			assert.Equal(t, "focal", *args["DISTRO_CODENAME"])
+		}
+	}
+}
+
+func TestEffectiveDistribution(t *testing.T) {
+	v, err := ParseOSRelease(osReleaseTest)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"debian"}, v.IDLike)
+		assert.Equal(t, UbuntuLinux, v.EffectiveDistribution())
+	}
+
+	v, err = ParseOSRelease("ID=pop\nID_LIKE=\"ubuntu debian\"\n")
+	if assert.NoError(t, err) {
+		assert.Equal(t, GenericLinux, v.ID)
+		assert.Equal(t, UbuntuLinux, v.EffectiveDistribution())
+	}
+
+	v, err = ParseOSRelease("ID=unknown\nID_LIKE=other\n")
+	if assert.NoError(t, err) {
+		assert.Equal(t, GenericLinux, v.EffectiveDistribution())
+	}
+}
@@ -16,17 +16,25 @@ package ssl
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
 	"encoding/pem"
 	"errors"
-	"math"
+	"fmt"
 	"math/big"
 	"time"
+
+	"github.com/rs/zerolog/log"
 )
 
 const (
 	// DefaultCertificateExpiration is the default certificate expiration (10 yrs)
 	DefaultCertificateExpiration = 10 * 8760 * time.Hour
+	// DefaultClockSkew is how far the start of the validity period is backdated by default
+	DefaultClockSkew = 5 * time.Minute
 )
 
 var keyUsage = map[string]x509.KeyUsage{
@@ -42,6 +50,9 @@ var keyUsage = map[string]x509.KeyUsage{
 	"decipher only":      x509.KeyUsageDecipherOnly,
 }
 
+// caUsages are the usages of a certificate authority
+var caUsages = []string{"cert sign", "crl sign"}
+
 var extKeyUsage = map[string]x509.ExtKeyUsage{
 	"any":              x509.ExtKeyUsageAny,
 	"server auth":      x509.ExtKeyUsageServerAuth,
@@ -58,6 +69,37 @@ var extKeyUsage = map[string]x509.ExtKeyUsage{
 	"netscape sgc":     x509.ExtKeyUsageNetscapeServerGatedCrypto,
 }
 
+// Profile specifies a common certificate usage profile
+type Profile int
+
+const (
+	profileNotSet Profile = iota
+	// WebServer specifies a tls server certificate
+	WebServer
+	// WebClient specifies a tls client certificate
+	WebClient
+	// CodeSigning specifies a code signing certificate
+	CodeSigning
+	// EmailProtection specifies an s/mime certificate
+	EmailProtection
+)
+
+// UsagesForProfile returns the usages for the specified profile
+func UsagesForProfile(p Profile) []string {
+	switch p {
+	case WebServer:
+		return []string{"signing", "key encipherment", "server auth"}
+	case WebClient:
+		return []string{"client auth"}
+	case CodeSigning:
+		return []string{"signing", "code signing"}
+	case EmailProtection:
+		return []string{"signing", "key encipherment", "email protection"}
+	}
+	log.Panic().Msg("unexpected certificate profile")
+	return nil
+}
+
 func sortUsages(usages []string) (x509.KeyUsage, []x509.ExtKeyUsage) {
 	var ku x509.KeyUsage
 	eku := []x509.ExtKeyUsage{}
@@ -71,17 +113,13 @@ func sortUsages(usages []string) (x509.KeyUsage, []x509.ExtKeyUsage) {
 	return ku, eku
 }
 
-func generateCertificateTemplate(csrData []byte, expires time.Duration, usage []string, isCA bool) (*x509.Certificate, Key, error) {
-	csr, err := ParseCertificateRequest(csrData)
+func generateCertificateTemplate(csrData []byte, expires time.Duration, usage []string, isCA bool, options *certOptions) (*x509.Certificate, Key, error) {
+	csr, err := ParseCertificateRequest(csrData, options.requestOptions()...)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	if expires.Seconds() == 0 {
-		expires = DefaultCertificateExpiration
-	}
-
-	serialNumber, err := rand.Int(rand.Reader, new(big.Int).SetInt64(math.MaxInt64))
+	template, err := options.newTemplate(expires, usage, isCA)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -92,68 +130,590 @@ func generateCertificateTemplate(csrData []byte, expires time.Duration, usage []
 	}
 
 	hosts := csr.parseHosts()
+	template.Subject = csr.Subject()
+	template.PublicKey = key.PublicKey()
+	template.PublicKeyAlgorithm = key.PublicKeyAlgorithm()
+	template.SignatureAlgorithm = key.SignatureAlgorithm()
+	template.IPAddresses = hosts.IPAddresses
+	template.EmailAddresses = hosts.EmailAddresses
+	template.URIs = hosts.URIs
+	template.DNSNames = hosts.DNSNames
+	return template, key, nil
+}
+
+// newTemplate returns a template with the validity period and key usages set
+func (o *certOptions) newTemplate(expires time.Duration, usage []string, isCA bool) (*x509.Certificate, error) {
+	// an expiration of 0 means the default certificate expiration
+	if expires < 0 {
+		return nil, errors.New("certificate expiration cannot be negative")
+	}
+	if expires.Seconds() == 0 {
+		expires = DefaultCertificateExpiration
+	}
+
 	ku, eku := sortUsages(usage)
 	if ku == 0 && len(eku) == 0 {
-		return nil, nil, errors.New("no key usage(s) specified")
+		return nil, errors.New("no key usage(s) specified")
 	}
 
 	now := time.Now()
 	return &x509.Certificate{
-		Subject:               *csr.subject(),
-		PublicKey:             key.PublicKey(),
-		PublicKeyAlgorithm:    key.PublicKeyAlgorithm(),
-		SignatureAlgorithm:    key.SignatureAlgorithm(),
-		IPAddresses:           hosts.IPAddresses,
-		EmailAddresses:        hosts.EmailAddresses,
-		URIs:                  hosts.URIs,
-		DNSNames:              hosts.DNSNames,
-		SerialNumber:          serialNumber,
-		NotBefore:             now.Add(-5 * time.Minute).UTC(),
+		NotBefore:             now.Add(-o.clockSkew).UTC(),
 		NotAfter:              now.Add(expires).UTC(),
 		KeyUsage:              ku,
 		ExtKeyUsage:           eku,
 		BasicConstraintsValid: true,
 		IsCA:                  isCA,
-	}, key, nil
+	}, nil
+}
+
+// CertOption applies an option when generating a certificate
+type CertOption interface {
+	Apply(o interface{}) error
 }
 
-// GenerateCA generates a self signed certificate authority pem encoded certificate
-func GenerateCA(csrData []byte, expires time.Duration) ([]byte, []byte, error) {
-	template, key, err := generateCertificateTemplate(csrData, expires, []string{"cert sign", "crl sign"}, true)
+type certOptions struct {
+	headers             map[string]string
+	deterministicSerial bool
+	serialBits          int
+	serialNumber        *big.Int
+	sanOnly             bool
+	maxPathLen          *int
+	clockSkew           time.Duration
+	ocspNoCheck         bool
+	caKeyPassphrase     []byte
+}
+
+func newCertOptions(opts ...CertOption) (*certOptions, error) {
+	o := &certOptions{serialBits: DefaultSerialBits, clockSkew: DefaultClockSkew}
+	for _, opt := range opts {
+		if err := opt.Apply(o); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// requestOptions returns the options used when parsing the certificate request
+func (o *certOptions) requestOptions() []CertificateRequestOption {
+	if o.sanOnly {
+		return []CertificateRequestOption{WithSANOnly()}
+	}
+	return nil
+}
+
+// applyTemplate applies the options to a generated certificate template
+func (o *certOptions) applyTemplate(template *x509.Certificate) error {
+	if o.ocspNoCheck {
+		applyOCSPNoCheck(template)
+	}
+	if o.maxPathLen != nil && template.IsCA {
+		template.MaxPathLen = *o.maxPathLen
+		template.MaxPathLenZero = *o.maxPathLen == 0
+	}
+	if o.serialNumber != nil {
+		template.SerialNumber = new(big.Int).Set(o.serialNumber)
+		return nil
+	}
+	if o.deterministicSerial {
+		template.SerialNumber = DeterministicSerial(template.Subject, template.NotBefore)
+		return nil
+	}
+	serialNumber, err := randomSerial(o.serialBits)
+	if err != nil {
+		return err
+	}
+	template.SerialNumber = serialNumber
+	return nil
+}
+
+// encode pem encodes the block attaching any configured headers
+func (o *certOptions) encode(blockType string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Headers: o.headers, Bytes: der})
+}
+
+// encodeKey re-encodes the pem encoded key attaching any configured headers
+func (o *certOptions) encodeKey(key Key) []byte {
+	if len(o.headers) == 0 {
+		return key.Encoded()
+	}
+	p, _ := pem.Decode(key.Encoded())
+	return o.encode(p.Type, p.Bytes)
+}
+
+type pemHeadersOption struct {
+	headers map[string]string
+}
+
+// WithPEMHeaders attaches the specified headers to the exported pem blocks
+func WithPEMHeaders(headers map[string]string) CertOption {
+	return &pemHeadersOption{headers: headers}
+}
+
+// Apply applies the pemHeadersOption
+func (o *pemHeadersOption) Apply(opts interface{}) error {
+	switch x := opts.(type) {
+	case *certOptions:
+		if x.headers == nil {
+			x.headers = make(map[string]string)
+		}
+		for k, v := range o.headers {
+			x.headers[k] = v
+		}
+	default:
+		return errors.New("unexpected error")
+	}
+	return nil
+}
+
+type deterministicSerialOption struct{}
+
+// WithDeterministicSerial derives the serial number from the subject and validity using DeterministicSerial
+// instead of generating a random one
+func WithDeterministicSerial() CertOption {
+	return &deterministicSerialOption{}
+}
+
+// Apply applies the deterministicSerialOption
+func (o *deterministicSerialOption) Apply(opts interface{}) error {
+	x, ok := opts.(*certOptions)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	x.deterministicSerial = true
+	return nil
+}
+
+// oidOCSPNoCheck is id-pkix-ocsp-nocheck (RFC 6960 section 4.2.2.2.1)
+var oidOCSPNoCheck = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+
+// applyOCSPNoCheck marks the template as an ocsp responder certificate which clients need not check for revocation
+func applyOCSPNoCheck(template *x509.Certificate) {
+	hasUsage := false
+	for _, u := range template.ExtKeyUsage {
+		hasUsage = hasUsage || u == x509.ExtKeyUsageOCSPSigning
+	}
+	if !hasUsage {
+		template.ExtKeyUsage = append(template.ExtKeyUsage, x509.ExtKeyUsageOCSPSigning)
+	}
+	template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: oidOCSPNoCheck, Value: asn1.NullBytes})
+}
+
+type ocspNoCheckOption struct{}
+
+// WithOCSPNoCheck generates an ocsp responder certificate with the ocsp signing usage and the
+// id-pkix-ocsp-nocheck extension
+func WithOCSPNoCheck() CertOption {
+	return &ocspNoCheckOption{}
+}
+
+// Apply applies the ocspNoCheckOption
+func (o *ocspNoCheckOption) Apply(opts interface{}) error {
+	x, ok := opts.(*certOptions)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	x.ocspNoCheck = true
+	return nil
+}
+
+type clockSkewOption struct {
+	skew time.Duration
+}
+
+// WithClockSkew backdates the start of the validity period by the specified duration to tolerate clock skew
+// between hosts instead of DefaultClockSkew, 0 disables backdating
+func WithClockSkew(skew time.Duration) CertOption {
+	return &clockSkewOption{skew: skew}
+}
+
+// Apply applies the clockSkewOption
+func (o *clockSkewOption) Apply(opts interface{}) error {
+	x, ok := opts.(*certOptions)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	if o.skew < 0 {
+		return fmt.Errorf("clock skew %s cannot be negative", o.skew)
+	}
+	x.clockSkew = o.skew
+	return nil
+}
+
+type maxPathLenOption struct {
+	maxPathLen int
+}
+
+// WithMaxPathLen limits the number of intermediate certificate authorities which may follow a generated
+// certificate authority in a chain, 0 only allows it to sign leaf certificates
+func WithMaxPathLen(n int) CertOption {
+	return &maxPathLenOption{maxPathLen: n}
+}
+
+// Apply applies the maxPathLenOption
+func (o *maxPathLenOption) Apply(opts interface{}) error {
+	x, ok := opts.(*certOptions)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	if o.maxPathLen < 0 {
+		return fmt.Errorf("max path length %d cannot be negative", o.maxPathLen)
+	}
+	x.maxPathLen = &o.maxPathLen
+	return nil
+}
+
+type serialBitsOption struct {
+	bits int
+}
+
+// WithSerialBits generates random serial numbers of exactly the specified bit length, which must be
+// between MinSerialBits and MaxSerialBits
+func WithSerialBits(bits int) CertOption {
+	return &serialBitsOption{bits: bits}
+}
+
+// Apply applies the serialBitsOption
+func (o *serialBitsOption) Apply(opts interface{}) error {
+	x, ok := opts.(*certOptions)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	if o.bits < MinSerialBits || o.bits > MaxSerialBits {
+		return fmt.Errorf("serial bit length %d must be between %d and %d", o.bits, MinSerialBits, MaxSerialBits)
+	}
+	x.serialBits = o.bits
+	return nil
+}
+
+type serialNumberOption struct {
+	serial *big.Int
+}
+
+// WithSerialNumber uses the specified serial number instead of generating one, it must be positive and
+// fit in 20 octets
+func WithSerialNumber(serial *big.Int) CertOption {
+	return &serialNumberOption{serial: serial}
+}
+
+// Apply applies the serialNumberOption
+func (o *serialNumberOption) Apply(opts interface{}) error {
+	x, ok := opts.(*certOptions)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	if o.serial == nil || o.serial.Sign() <= 0 {
+		return errors.New("serial number must be positive")
+	}
+	if o.serial.BitLen() > MaxSerialBits {
+		return fmt.Errorf("serial number %s does not fit in %d octets", o.serial, maxSerialOctets)
+	}
+	x.serialNumber = new(big.Int).Set(o.serial)
+	return nil
+}
+
+type caKeyPassphraseOption struct {
+	passphrase []byte
+}
+
+// WithCAKeyPassphrase decrypts an encrypted ca private key such as one generated by GenerateEncryptedKey
+// with the specified passphrase
+func WithCAKeyPassphrase(passphrase []byte) CertOption {
+	return &caKeyPassphraseOption{passphrase: passphrase}
+}
+
+// Apply applies the caKeyPassphraseOption
+func (o *caKeyPassphraseOption) Apply(opts interface{}) error {
+	x, ok := opts.(*certOptions)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	if len(o.passphrase) == 0 {
+		return errors.New("passphrase cannot be empty")
+	}
+	x.caKeyPassphrase = o.passphrase
+	return nil
+}
+
+// Certificate represents a generated certificate
+type Certificate interface {
+	SerialNumber() *big.Int
+	NotAfter() time.Time
+	// Fingerprint returns the hex encoded SHA-256 digest of the DER encoded certificate
+	Fingerprint() string
+	PEM() []byte
+}
+
+type baseCertificate struct {
+	cert    *x509.Certificate
+	encoded []byte
+}
+
+func (c *baseCertificate) SerialNumber() *big.Int {
+	return c.cert.SerialNumber
+}
+
+func (c *baseCertificate) NotAfter() time.Time {
+	return c.cert.NotAfter
+}
+
+func (c *baseCertificate) Fingerprint() string {
+	sum := sha256.Sum256(c.cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *baseCertificate) PEM() []byte {
+	return c.encoded
+}
+
+func newCertificate(der []byte, options *certOptions) (*baseCertificate, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &baseCertificate{cert: cert, encoded: options.encode("CERTIFICATE", der)}, nil
+}
+
+func generateCACertificate(csrData []byte, expires time.Duration, options *certOptions) (*baseCertificate, Key, error) {
+	template, key, err := generateCertificateTemplate(csrData, expires, caUsages, true, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := options.applyTemplate(template); err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.PublicKey(), key.PrivateKey())
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := newCertificate(der, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+func generateCertificate(csrData, ca, caKey []byte, expires time.Duration, usage []string, isCA bool, options *certOptions) (*baseCertificate, Key, error) {
+	template, key, err := generateCertificateTemplate(csrData, expires, usage, isCA, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := options.applyTemplate(template); err != nil {
+		return nil, nil, err
+	}
+
+	caCert, err := parseCertificate(ca)
+	if err != nil {
+		return nil, nil, err
+	}
+	if isCA && !caCert.IsCA {
+		return nil, nil, errors.New("parent certificate is not a certificate authority")
+	}
+
+	caPrivateKey, err := parsePrivateKeyWithPassphrase(caKey, options.caKeyPassphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, key.PublicKey(), caPrivateKey.PrivateKey())
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := newCertificate(der, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// GenerateCACertificate generates a self signed certificate authority returning the certificate and its key
+func GenerateCACertificate(csrData []byte, expires time.Duration, opts ...CertOption) (Certificate, Key, error) {
+	options, err := newCertOptions(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return generateCACertificate(csrData, expires, options)
+}
+
+// GenerateCertificate generates a new certificate signed by the pem encoded ca returning the certificate and its key
+func GenerateCertificate(csrData, ca, caKey []byte, expires time.Duration, usage []string, opts ...CertOption) (Certificate, Key, error) {
+	options, err := newCertOptions(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return generateCertificate(csrData, ca, caKey, expires, usage, false, options)
+}
+
+// GenerateCA generates a self signed certificate authority pem encoded certificate, an expires of 0 uses
+// DefaultCertificateExpiration
+func GenerateCA(csrData []byte, expires time.Duration, opts ...CertOption) ([]byte, []byte, error) {
+	options, err := newCertOptions(opts...)
 	if err != nil {
 		return nil, nil, err
 	}
-	cert, err := x509.CreateCertificate(rand.Reader, template, template, key.PublicKey(), key.PrivateKey())
+	cert, key, err := generateCACertificate(csrData, expires, options)
 	if err != nil {
 		return nil, nil, err
 	}
+	return cert.PEM(), options.encodeKey(key), nil
+}
 
-	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), key.Encoded(), nil
+// GenerateIntermediateCA generates an intermediate certificate authority signed by the pem encoded parent, use
+// WithMaxPathLen to limit how many further intermediates it can sign
+func GenerateIntermediateCA(csrData, parentCert, parentKey []byte, expires time.Duration, opts ...CertOption) ([]byte, []byte, error) {
+	options, err := newCertOptions(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, key, err := generateCertificate(csrData, parentCert, parentKey, expires, caUsages, true, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert.PEM(), options.encodeKey(key), nil
 }
 
-// Generate generates a new certificate
-func Generate(csrData, ca, caKey []byte, expires time.Duration, usage []string) ([]byte, []byte, error) {
-	template, key, err := generateCertificateTemplate(csrData, expires, usage, false)
+// RekeyCA re-issues a self signed certificate authority with a fresh key of the same algorithm and size, keeping
+// the subject, constraints and extensions of the old certificate, an expires of 0 uses DefaultCertificateExpiration
+func RekeyCA(oldCACertPEM, oldCAKeyPEM []byte, expires time.Duration, opts ...CertOption) ([]byte, []byte, error) {
+	options, err := newCertOptions(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	old, err := parseCertificate(oldCACertPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !old.IsCA {
+		return nil, nil, errors.New("certificate is not a certificate authority")
+	}
+	oldKey, err := ParseKey(oldCAKeyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := GenerateKey(oldKey.Algorithm(), oldKey.Size())
 	if err != nil {
 		return nil, nil, err
 	}
 
-	p, _ := pem.Decode(ca)
-	caCert, err := x509.ParseCertificate(p.Bytes)
+	template, err := options.newTemplate(expires, caUsages, true)
 	if err != nil {
 		return nil, nil, err
 	}
+	template.Subject = old.Subject
+	template.PublicKey = key.PublicKey()
+	template.PublicKeyAlgorithm = key.PublicKeyAlgorithm()
+	template.SignatureAlgorithm = key.SignatureAlgorithm()
+	template.KeyUsage = old.KeyUsage
+	template.ExtKeyUsage = old.ExtKeyUsage
+	template.UnknownExtKeyUsage = old.UnknownExtKeyUsage
+	template.BasicConstraintsValid = old.BasicConstraintsValid
+	template.MaxPathLen = old.MaxPathLen
+	template.MaxPathLenZero = old.MaxPathLenZero
+	template.DNSNames = old.DNSNames
+	template.EmailAddresses = old.EmailAddresses
+	template.IPAddresses = old.IPAddresses
+	template.URIs = old.URIs
+	template.PermittedDNSDomainsCritical = old.PermittedDNSDomainsCritical
+	template.PermittedDNSDomains = old.PermittedDNSDomains
+	template.ExcludedDNSDomains = old.ExcludedDNSDomains
+	template.PermittedIPRanges = old.PermittedIPRanges
+	template.ExcludedIPRanges = old.ExcludedIPRanges
+	template.PermittedEmailAddresses = old.PermittedEmailAddresses
+	template.ExcludedEmailAddresses = old.ExcludedEmailAddresses
+	template.PermittedURIDomains = old.PermittedURIDomains
+	template.ExcludedURIDomains = old.ExcludedURIDomains
+	template.CRLDistributionPoints = old.CRLDistributionPoints
+	template.OCSPServer = old.OCSPServer
+	template.IssuingCertificateURL = old.IssuingCertificateURL
+	template.PolicyIdentifiers = old.PolicyIdentifiers
+	if err := options.applyTemplate(template); err != nil {
+		return nil, nil, err
+	}
 
-	caPrivateKey, err := parsePrivateKey(caKey)
+	// the subject key identifier is derived from the new public key
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.PublicKey(), key.PrivateKey())
 	if err != nil {
 		return nil, nil, err
 	}
+	return options.encode("CERTIFICATE", der), options.encodeKey(key), nil
+}
 
-	cert, err := x509.CreateCertificate(rand.Reader, template, caCert, key.PublicKey(), caPrivateKey.PrivateKey())
+// Generate generates a new certificate, an expires of 0 uses DefaultCertificateExpiration
+func Generate(csrData, ca, caKey []byte, expires time.Duration, usage []string, opts ...CertOption) ([]byte, []byte, error) {
+	options, err := newCertOptions(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, key, err := generateCertificate(csrData, ca, caKey, expires, usage, false, options)
 	if err != nil {
 		return nil, nil, err
 	}
-	encoded := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+	return cert.PEM(), options.encodeKey(key), nil
+}
+
+// SignCSR issues a certificate signed by the pem encoded ca for the pem encoded certificate request, using the
+// subject, SANs and public key from the request so the requester keeps its private key
+func SignCSR(csrPEM, ca, caKey []byte, expires time.Duration, usage []string, opts ...CertOption) ([]byte, error) {
+	options, err := newCertOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	csr, err := ParsePEMCertificateRequest(csrPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := options.newTemplate(expires, usage, false)
+	if err != nil {
+		return nil, err
+	}
+	template.Subject = csr.Subject
+	template.PublicKey = csr.PublicKey
+	template.PublicKeyAlgorithm = csr.PublicKeyAlgorithm
+	template.IPAddresses = csr.IPAddresses
+	template.EmailAddresses = csr.EmailAddresses
+	template.URIs = csr.URIs
+	template.DNSNames = csr.DNSNames
+	if err := options.applyTemplate(template); err != nil {
+		return nil, err
+	}
+
+	caCert, err := parseCertificate(ca)
+	if err != nil {
+		return nil, err
+	}
+
+	caPrivateKey, err := parsePrivateKeyWithPassphrase(caKey, options.caKeyPassphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, csr.PublicKey, caPrivateKey.PrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	return options.encode("CERTIFICATE", der), nil
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	p, _ := pem.Decode(certPEM)
+	if p == nil {
+		return nil, errors.New("cannot decode certificate")
+	}
+	return x509.ParseCertificate(p.Bytes)
+}
 
-	return encoded, key.Encoded(), nil
+// CertificateCoversHost checks if the pem encoded certificate is valid for the specified host
+func CertificateCoversHost(certPEM []byte, host string) (bool, error) {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return false, err
+	}
+	if err := cert.VerifyHostname(host); err != nil {
+		return false, nil
+	}
+	return true, nil
 }
@@ -15,13 +15,28 @@
 package ssl
 
 import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
 	"io/ioutil"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+const (
+	testWildcardCSR = `
+keyAlgorithm: ecdsa
+commonName: "*.example.com"
+hosts:
+    - "*.example.com"
+    - 10.1.0.1
+`
+)
+
 func TestGenerateCA(t *testing.T) {
 	cert, key, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
 	if assert.NoError(t, err) {
@@ -40,3 +55,299 @@ func TestGenerate(t *testing.T) {
 		}
 	}
 }
+
+func TestUsagesForProfile(t *testing.T) {
+	var testValues = []struct {
+		profile Profile
+		ku      x509.KeyUsage
+		eku     []x509.ExtKeyUsage
+	}{
+		{WebServer, x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}},
+		{WebClient, 0, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}},
+		{CodeSigning, x509.KeyUsageDigitalSignature, []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}},
+		{EmailProtection, x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment, []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection}},
+	}
+
+	for _, tv := range testValues {
+		usages := UsagesForProfile(tv.profile)
+		for _, u := range usages {
+			_, isKeyUsage := keyUsage[u]
+			_, isExtKeyUsage := extKeyUsage[u]
+			assert.True(t, isKeyUsage || isExtKeyUsage, "unknown usage %s", u)
+		}
+		ku, eku := sortUsages(usages)
+		assert.Equal(t, tv.ku, ku)
+		assert.Equal(t, tv.eku, eku)
+	}
+
+	assert.Panics(t, func() { UsagesForProfile(profileNotSet) })
+}
+
+func TestCertificateCoversHost(t *testing.T) {
+	caCert, caKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if assert.NoError(t, err) {
+		cert, _, err := Generate([]byte(testWildcardCSR), caCert, caKey, DefaultCertificateExpiration, UsagesForProfile(WebServer))
+		if assert.NoError(t, err) {
+			var testValues = []struct {
+				host    string
+				outcome bool
+			}{
+				{"www.example.com", true},
+				{"api.example.com", true},
+				{"example.com", false},
+				{"a.b.example.com", false},
+				{"www.example.org", false},
+				{"10.1.0.1", true},
+				{"10.1.0.2", false},
+			}
+			for _, tv := range testValues {
+				ok, err := CertificateCoversHost(cert, tv.host)
+				if assert.NoError(t, err) {
+					assert.Equal(t, tv.outcome, ok, tv.host)
+				}
+			}
+		}
+	}
+
+	_, err = CertificateCoversHost([]byte("not a certificate"), "www.example.com")
+	assert.Error(t, err)
+}
+
+func TestPEMHeaders(t *testing.T) {
+	headers := map[string]string{"Comment": "limepacker"}
+	caCert, caKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration, WithPEMHeaders(headers))
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, encoded := range [][]byte{caCert, caKey} {
+		p, _ := pem.Decode(encoded)
+		if assert.NotNil(t, p) {
+			assert.Equal(t, headers, p.Headers)
+		}
+	}
+
+	_, err = parseCertificate(caCert)
+	assert.NoError(t, err)
+	_, err = parsePrivateKey(caKey)
+	assert.NoError(t, err)
+
+	cert, _, err := Generate([]byte(testCSR), caCert, caKey, DefaultCertificateExpiration, UsagesForProfile(WebServer), WithPEMHeaders(headers))
+	if assert.NoError(t, err) {
+		p, _ := pem.Decode(cert)
+		if assert.NotNil(t, p) {
+			assert.Equal(t, headers, p.Headers)
+		}
+	}
+}
+
+func TestNegativeExpiration(t *testing.T) {
+	_, _, err := GenerateCA([]byte(testCSR), -time.Hour)
+	assert.Error(t, err)
+
+	caCert, caKey, err := GenerateCA([]byte(testCSR), 0)
+	if assert.NoError(t, err) {
+		cert, err := parseCertificate(caCert)
+		if assert.NoError(t, err) {
+			assert.True(t, cert.NotAfter.After(time.Now().Add(DefaultCertificateExpiration-time.Hour)))
+		}
+		_, _, err = Generate([]byte(testCSR), caCert, caKey, -time.Hour, UsagesForProfile(WebServer))
+		assert.Error(t, err)
+	}
+}
+
+func TestGenerateCertificate(t *testing.T) {
+	ca, caKey, err := GenerateCACertificate([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 1, ca.SerialNumber().Sign())
+
+	cert, key, err := GenerateCertificate([]byte(testCSR), ca.PEM(), caKey.Encoded(), time.Hour, UsagesForProfile(WebServer))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotNil(t, key)
+
+	parsed, err := parseCertificate(cert.PEM())
+	if assert.NoError(t, err) {
+		assert.Equal(t, parsed.SerialNumber, cert.SerialNumber())
+		assert.Equal(t, parsed.NotAfter, cert.NotAfter())
+		assert.WithinDuration(t, time.Now().Add(time.Hour), cert.NotAfter(), time.Minute)
+		sum := sha256.Sum256(parsed.Raw)
+		assert.Equal(t, hex.EncodeToString(sum[:]), cert.Fingerprint())
+		assert.Len(t, cert.Fingerprint(), 64)
+	}
+
+	_, _, err = GenerateCertificate([]byte(testCSR), []byte("not a certificate"), caKey.Encoded(), time.Hour, UsagesForProfile(WebServer))
+	assert.Error(t, err)
+}
+
+func TestSignCSR(t *testing.T) {
+	csr, err := ParseCertificateRequest([]byte(testCSR))
+	if !assert.NoError(t, err) {
+		return
+	}
+	key, err := csr.generateKey()
+	if !assert.NoError(t, err) {
+		return
+	}
+	csrPEM, err := csr.generate(key, nil, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	parsed, err := ParsePEMCertificateRequest(csrPEM)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "test.example.com", parsed.Subject.CommonName)
+	}
+
+	ca, caKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	certPEM, err := SignCSR(csrPEM, ca, caKey, time.Hour, UsagesForProfile(WebServer))
+	if assert.NoError(t, err) {
+		cert, err := parseCertificate(certPEM)
+		if assert.NoError(t, err) {
+			assert.Equal(t, key.PublicKey(), cert.PublicKey)
+			assert.Equal(t, csr.Subject().CommonName, cert.Subject.CommonName)
+			assert.Equal(t, []string{"example.com", "localhost"}, cert.DNSNames)
+			assert.Equal(t, []string{"admin@example.com"}, cert.EmailAddresses)
+			assert.Len(t, cert.IPAddresses, 1)
+		}
+	}
+
+	_, err = ParsePEMCertificateRequest(ca)
+	assert.Error(t, err)
+	_, err = SignCSR([]byte(testCSR), ca, caKey, time.Hour, UsagesForProfile(WebServer))
+	assert.Error(t, err)
+}
+
+func TestSANOnly(t *testing.T) {
+	sanOnly := `
+keyAlgorithm: ecdsa
+hosts:
+    - san.example.com
+    - 10.1.0.1
+`
+	_, err := ParseCertificateRequest([]byte(sanOnly))
+	assert.Error(t, err)
+	_, err = ParseCertificateRequest([]byte("keyAlgorithm: ecdsa\n"), WithSANOnly())
+	assert.Error(t, err)
+	_, err = ParseCertificateRequest([]byte(sanOnly), WithSANOnly())
+	assert.NoError(t, err)
+
+	ca, caKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	certPEM, _, err := Generate([]byte(sanOnly), ca, caKey, time.Hour, UsagesForProfile(WebServer), WithSANOnly())
+	if assert.NoError(t, err) {
+		cert, err := parseCertificate(certPEM)
+		if assert.NoError(t, err) {
+			assert.Empty(t, cert.Subject.CommonName)
+			assert.Empty(t, cert.Subject.Names)
+			assert.Equal(t, []string{"san.example.com"}, cert.DNSNames)
+			assert.Len(t, cert.IPAddresses, 1)
+		}
+	}
+}
+
+func TestRekeyCA(t *testing.T) {
+	oldCert, oldKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration, WithMaxPathLen(1))
+	if !assert.NoError(t, err) {
+		return
+	}
+	newCert, newKey, err := RekeyCA(oldCert, oldKey, time.Hour)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	old, _ := parseCertificate(oldCert)
+	rekeyed, err := parseCertificate(newCert)
+	if assert.NoError(t, err) {
+		assert.Equal(t, old.RawSubject, rekeyed.RawSubject)
+		assert.True(t, rekeyed.IsCA)
+		assert.Equal(t, 1, rekeyed.MaxPathLen)
+		assert.Equal(t, old.KeyUsage, rekeyed.KeyUsage)
+		assert.Equal(t, old.DNSNames, rekeyed.DNSNames)
+		assert.NotEqual(t, old.PublicKey, rekeyed.PublicKey)
+		assert.NotEqual(t, old.SubjectKeyId, rekeyed.SubjectKeyId)
+		assert.NotEqual(t, old.SerialNumber, rekeyed.SerialNumber)
+		assert.NoError(t, rekeyed.CheckSignatureFrom(rekeyed))
+	}
+
+	o, _ := ParseKey(oldKey)
+	k, err := ParseKey(newKey)
+	if assert.NoError(t, err) {
+		assert.Equal(t, o.Algorithm(), k.Algorithm())
+		assert.Equal(t, o.Size(), k.Size())
+	}
+
+	ca, caKey, _ := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	leaf, leafKey, err := Generate([]byte(testCSR), ca, caKey, time.Hour, UsagesForProfile(WebServer))
+	if assert.NoError(t, err) {
+		_, _, err = RekeyCA(leaf, leafKey, time.Hour)
+		assert.Error(t, err)
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	for _, skew := range []time.Duration{DefaultClockSkew, time.Hour, 0} {
+		opts := []CertOption{}
+		if skew != DefaultClockSkew {
+			opts = append(opts, WithClockSkew(skew))
+		}
+		now := time.Now()
+		ca, _, err := GenerateCACertificate([]byte(testCSR), DefaultCertificateExpiration, opts...)
+		if assert.NoError(t, err) {
+			cert, _ := parseCertificate(ca.PEM())
+			assert.WithinDuration(t, now.Add(-skew), cert.NotBefore, 2*time.Second)
+		}
+	}
+
+	ca, caKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if assert.NoError(t, err) {
+		now := time.Now()
+		leaf, _, err := Generate([]byte(testCSR), ca, caKey, time.Hour, UsagesForProfile(WebServer), WithClockSkew(time.Hour))
+		if assert.NoError(t, err) {
+			cert, _ := parseCertificate(leaf)
+			assert.WithinDuration(t, now.Add(-time.Hour), cert.NotBefore, 2*time.Second)
+		}
+		_, _, err = Generate([]byte(testCSR), ca, caKey, time.Hour, UsagesForProfile(WebServer), WithClockSkew(-time.Minute))
+		assert.Error(t, err)
+	}
+
+	_, _, err = GenerateCA([]byte(testCSR), DefaultCertificateExpiration, WithClockSkew(-time.Minute))
+	assert.Error(t, err)
+}
+
+func TestOCSPNoCheck(t *testing.T) {
+	ca, caKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	responder, _, err := Generate([]byte(testCSR), ca, caKey, time.Hour, []string{"signing", "ocsp signing"}, WithOCSPNoCheck())
+	if !assert.NoError(t, err) {
+		return
+	}
+	cert, err := parseCertificate(responder)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}, cert.ExtKeyUsage)
+		found := false
+		for _, ext := range cert.Extensions {
+			if ext.Id.Equal(oidOCSPNoCheck) {
+				found = true
+				assert.False(t, ext.Critical)
+				assert.Equal(t, []byte{0x05, 0x00}, ext.Value)
+			}
+		}
+		assert.True(t, found)
+	}
+
+	leaf, _, err := Generate([]byte(testCSR), ca, caKey, time.Hour, []string{"signing"}, WithOCSPNoCheck())
+	if assert.NoError(t, err) {
+		cert, _ := parseCertificate(leaf)
+		assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}, cert.ExtKeyUsage)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"time"
+)
+
+// SplitChain splits a pem encoded full chain into the leaf certificate and the remaining chain
+func SplitChain(fullchainPEM []byte) ([]byte, [][]byte, error) {
+	certs := [][]byte{}
+	rest := fullchainPEM
+	for {
+		var p *pem.Block
+		p, rest = pem.Decode(rest)
+		if p == nil {
+			break
+		}
+		if p.Type != "CERTIFICATE" {
+			continue
+		}
+		certs = append(certs, pem.EncodeToMemory(p))
+	}
+
+	if len(certs) == 0 {
+		return nil, nil, errors.New("no certificates found")
+	}
+
+	return certs[0], certs[1:], nil
+}
+
+// CABundle returns the issuer chain of a pem encoded full chain without the leaf certificate,
+// ordered from the leaf's issuer up to the root, for use as a ca-bundle.pem
+func CABundle(fullchainPEM []byte) ([]byte, error) {
+	leafPEM, chainPEM, err := SplitChain(fullchainPEM)
+	if err != nil {
+		return nil, err
+	}
+	current, err := parseCertificate(leafPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := []*x509.Certificate{}
+	for _, certPEM := range chainPEM {
+		cert, err := parseCertificate(certPEM)
+		if err != nil {
+			return nil, err
+		}
+		remaining = append(remaining, cert)
+	}
+
+	ordered := [][]byte{}
+	for len(remaining) > 0 {
+		found := -1
+		for i, cert := range remaining {
+			if current.CheckSignatureFrom(cert) == nil {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			return nil, errors.New("certificate chain contains certificates which are not issuers of the leaf")
+		}
+		current = remaining[found]
+		ordered = append(ordered, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: current.Raw}))
+		remaining = append(remaining[:found], remaining[found+1:]...)
+	}
+
+	return bytes.Join(ordered, nil), nil
+}
+
+// ParseChain parses all the certificates in a pem encoded chain in the order they appear
+func ParseChain(chainPEM []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	rest := chainPEM
+	for {
+		var p *pem.Block
+		p, rest = pem.Decode(rest)
+		if p == nil {
+			break
+		}
+		if p.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(p.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+	return certs, nil
+}
+
+// GenerateChain generates a new certificate like Generate but returns it as a full chain followed by its
+// issuers, leaf first as expected by most tls servers. The ca chain must start with the issuing ca whose key
+// is caKey and may be followed by further intermediates and the root.
+func GenerateChain(csrData, caChain, caKey []byte, expires time.Duration, usage []string, opts ...CertOption) ([]byte, []byte, error) {
+	leafPEM, keyPEM, err := Generate(csrData, caChain, caKey, expires, usage, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	bundle, err := CABundle(append(append([]byte{}, leafPEM...), caChain...))
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(leafPEM, bundle...), keyPEM, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"bytes"
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitChain(t *testing.T) {
+	rootCert, rootKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	otherCert, _, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	leafCert, _, err := Generate([]byte(testCSR), rootCert, rootKey, DefaultCertificateExpiration, UsagesForProfile(WebServer))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	leaf, chain, err := SplitChain(bytes.Join([][]byte{leafCert, otherCert, rootCert}, nil))
+	if assert.NoError(t, err) {
+		assert.Equal(t, leafCert, leaf)
+		assert.Equal(t, [][]byte{otherCert, rootCert}, chain)
+	}
+
+	leaf, chain, err = SplitChain(leafCert)
+	if assert.NoError(t, err) {
+		assert.Equal(t, leafCert, leaf)
+		assert.Empty(t, chain)
+	}
+
+	_, _, err = SplitChain([]byte("not a certificate"))
+	assert.Error(t, err)
+}
+
+func generateTestIntermediate(t *testing.T, caCert, caKey []byte) ([]byte, []byte) {
+	cert, key, err := GenerateIntermediateCA([]byte(testCSR), caCert, caKey, DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return cert, key
+}
+
+func TestCABundle(t *testing.T) {
+	rootCert, rootKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	intermediateCert, intermediateKey := generateTestIntermediate(t, rootCert, rootKey)
+	leafCert, _, err := Generate([]byte(testCSR), intermediateCert, intermediateKey, DefaultCertificateExpiration, UsagesForProfile(WebServer))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the root is listed before the intermediate to check the bundle is reordered
+	bundle, err := CABundle(bytes.Join([][]byte{leafCert, rootCert, intermediateCert}, nil))
+	if assert.NoError(t, err) {
+		assert.Equal(t, bytes.Join([][]byte{intermediateCert, rootCert}, nil), bundle)
+		assert.False(t, bytes.Contains(bundle, leafCert))
+	}
+
+	otherCert, _, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = CABundle(bytes.Join([][]byte{leafCert, intermediateCert, otherCert}, nil))
+	assert.Error(t, err)
+}
+
+func TestGenerateIntermediateCA(t *testing.T) {
+	rootCert, rootKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	intermediateCert, intermediateKey, err := GenerateIntermediateCA([]byte(testCSR), rootCert, rootKey, DefaultCertificateExpiration, WithMaxPathLen(0))
+	if !assert.NoError(t, err) {
+		return
+	}
+	leafCert, _, err := Generate([]byte(testCSR), intermediateCert, intermediateKey, DefaultCertificateExpiration, UsagesForProfile(WebServer))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	root, _ := parseCertificate(rootCert)
+	intermediate, err := parseCertificate(intermediateCert)
+	if assert.NoError(t, err) {
+		assert.True(t, intermediate.IsCA)
+		assert.Equal(t, 0, intermediate.MaxPathLen)
+		assert.True(t, intermediate.MaxPathLenZero)
+		assert.NoError(t, intermediate.CheckSignatureFrom(root))
+		assert.Error(t, intermediate.CheckSignatureFrom(intermediate))
+	}
+	leaf, err := parseCertificate(leafCert)
+	if assert.NoError(t, err) {
+		roots := x509.NewCertPool()
+		roots.AddCert(root)
+		intermediates := x509.NewCertPool()
+		intermediates.AddCert(intermediate)
+		_, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, DNSName: "example.com"})
+		assert.NoError(t, err)
+	}
+
+	unconstrained, _, err := GenerateIntermediateCA([]byte(testCSR), rootCert, rootKey, DefaultCertificateExpiration)
+	if assert.NoError(t, err) {
+		cert, _ := parseCertificate(unconstrained)
+		assert.False(t, cert.MaxPathLenZero)
+		assert.Equal(t, -1, cert.MaxPathLen)
+	}
+
+	_, _, err = GenerateIntermediateCA([]byte(testCSR), leafCert, rootKey, DefaultCertificateExpiration)
+	assert.Error(t, err)
+	_, _, err = GenerateIntermediateCA([]byte(testCSR), rootCert, rootKey, DefaultCertificateExpiration, WithMaxPathLen(-1))
+	assert.Error(t, err)
+}
+
+func TestGenerateChain(t *testing.T) {
+	rootCert, rootKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	intermediateCert, intermediateKey := generateTestIntermediate(t, rootCert, rootKey)
+
+	chainPEM, keyPEM, err := GenerateChain([]byte(testCSR), bytes.Join([][]byte{intermediateCert, rootCert}, nil), intermediateKey, DefaultCertificateExpiration, UsagesForProfile(WebServer))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEmpty(t, keyPEM)
+
+	chain, err := ParseChain(chainPEM)
+	if !assert.NoError(t, err) || !assert.Len(t, chain, 3) {
+		return
+	}
+	root, _ := parseCertificate(rootCert)
+	intermediate, _ := parseCertificate(intermediateCert)
+	assert.False(t, chain[0].IsCA)
+	assert.Equal(t, intermediate.Raw, chain[1].Raw)
+	assert.Equal(t, root.Raw, chain[2].Raw)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(chain[2])
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(chain[1])
+	_, err = chain[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, DNSName: "localhost"})
+	assert.NoError(t, err)
+
+	_, err = ParseChain([]byte("not a certificate"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// PEMType specifies the kind of data contained in a pem block
+type PEMType int
+
+const (
+	pemTypeNotSet PEMType = iota
+	// PEMPrivateKey a private key
+	PEMPrivateKey
+	// PEMCertificate a certificate
+	PEMCertificate
+	// PEMCertificateRequest a certificate request
+	PEMCertificateRequest
+	// PEMPublicKey a public key
+	PEMPublicKey
+)
+
+func (t PEMType) String() string {
+	switch t {
+	case PEMPrivateKey:
+		return "private key"
+	case PEMCertificate:
+		return "certificate"
+	case PEMCertificateRequest:
+		return "certificate request"
+	case PEMPublicKey:
+		return "public key"
+	}
+	log.Panic().Msg("unexpected pem type")
+	return ""
+}
+
+// ClassifyPEM returns the kind of data in the first pem block based on the block type
+func ClassifyPEM(pemData []byte) (PEMType, error) {
+	p, _ := pem.Decode(pemData)
+	if p == nil {
+		return pemTypeNotSet, errors.New("no pem data found")
+	}
+
+	switch {
+	case strings.HasSuffix(p.Type, "PRIVATE KEY"):
+		return PEMPrivateKey, nil
+	case strings.HasSuffix(p.Type, "PUBLIC KEY"):
+		return PEMPublicKey, nil
+	case p.Type == "CERTIFICATE":
+		return PEMCertificate, nil
+	case p.Type == "CERTIFICATE REQUEST", p.Type == "NEW CERTIFICATE REQUEST":
+		return PEMCertificateRequest, nil
+	}
+	return pemTypeNotSet, fmt.Errorf("unknown pem block type %s", p.Type)
+}
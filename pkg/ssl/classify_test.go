@@ -0,0 +1,73 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyPEM(t *testing.T) {
+	cert, key, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	rsaKey, err := GenerateKey(RSAKey, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+	csr, err := ParseCertificateRequest([]byte(testCSR))
+	if !assert.NoError(t, err) {
+		return
+	}
+	ecKey, err := parsePrivateKey(key)
+	if !assert.NoError(t, err) {
+		return
+	}
+	request, err := csr.generate(ecKey, nil, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	pub, err := x509.MarshalPKIXPublicKey(ecKey.PublicKey())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var testValues = []struct {
+		in       []byte
+		expected PEMType
+	}{
+		{cert, PEMCertificate},
+		{key, PEMPrivateKey},
+		{rsaKey.Encoded(), PEMPrivateKey},
+		{request, PEMCertificateRequest},
+		{pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}), PEMPublicKey},
+	}
+	for _, tv := range testValues {
+		actual, err := ClassifyPEM(tv.in)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tv.expected, actual)
+		}
+	}
+
+	_, err = ClassifyPEM([]byte("not pem"))
+	assert.Error(t, err)
+	_, err = ClassifyPEM(pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: []byte{0}}))
+	assert.Error(t, err)
+	assert.Panics(t, func() { _ = pemTypeNotSet.String() })
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// DefaultCRLExpiration is the default time until the next certificate revocation list update
+const DefaultCRLExpiration = 7 * 24 * time.Hour
+
+// RevokedCertificate identifies a revoked certificate
+type RevokedCertificate struct {
+	SerialNumber   *big.Int  // SerialNumber is the serial number of the revoked certificate
+	RevocationTime time.Time // RevocationTime is when the certificate was revoked
+}
+
+// GenerateCRL generates a pem encoded certificate revocation list signed by the pem encoded ca, a next update
+// of 0 uses DefaultCRLExpiration. Of the options only WithCAKeyPassphrase applies.
+func GenerateCRL(caCert, caKey []byte, revoked []RevokedCertificate, nextUpdate time.Duration, opts ...CertOption) ([]byte, error) {
+	options, err := newCertOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if nextUpdate < 0 {
+		return nil, errors.New("crl next update cannot be negative")
+	}
+	if nextUpdate == 0 {
+		nextUpdate = DefaultCRLExpiration
+	}
+
+	ca, err := parseCertificate(caCert)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePrivateKeyWithPassphrase(caKey, options.caKeyPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.PrivateKey().(crypto.Signer)
+	if !ok {
+		return nil, errors.New("ca key cannot sign")
+	}
+
+	entries := make([]pkix.RevokedCertificate, 0, len(revoked))
+	for _, r := range revoked {
+		if r.SerialNumber == nil {
+			return nil, errors.New("revoked certificate is missing a serial number")
+		}
+		entries = append(entries, pkix.RevokedCertificate{SerialNumber: r.SerialNumber, RevocationTime: r.RevocationTime.UTC()})
+	}
+
+	// the crl number must increase with each issued list so it is derived from the issue time
+	now := time.Now()
+	template := &x509.RevocationList{
+		RevokedCertificates: entries,
+		Number:              big.NewInt(now.UnixNano()),
+		ThisUpdate:          now.UTC(),
+		NextUpdate:          now.Add(nextUpdate).UTC(),
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca, signer)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateCRL(t *testing.T) {
+	caCert, caKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	leaf, _, err := GenerateCertificate([]byte(testCSR), caCert, caKey, time.Hour, UsagesForProfile(WebServer))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	revokedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	crlPEM, err := GenerateCRL(caCert, caKey, []RevokedCertificate{{SerialNumber: leaf.SerialNumber(), RevocationTime: revokedAt}}, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	p, _ := pem.Decode(crlPEM)
+	if assert.NotNil(t, p) {
+		assert.Equal(t, "X509 CRL", p.Type)
+		crl, err := x509.ParseRevocationList(p.Bytes)
+		if assert.NoError(t, err) {
+			ca, _ := parseCertificate(caCert)
+			assert.NoError(t, crl.CheckSignatureFrom(ca))
+			assert.WithinDuration(t, time.Now().Add(DefaultCRLExpiration), crl.NextUpdate, time.Minute)
+			if assert.Len(t, crl.RevokedCertificateEntries, 1) {
+				assert.Equal(t, leaf.SerialNumber(), crl.RevokedCertificateEntries[0].SerialNumber)
+				assert.True(t, revokedAt.Equal(crl.RevokedCertificateEntries[0].RevocationTime))
+			}
+		}
+	}
+
+	_, err = GenerateCRL(caCert, caKey, []RevokedCertificate{{SerialNumber: big.NewInt(1)}}, -time.Hour)
+	assert.Error(t, err)
+	_, err = GenerateCRL(caCert, caKey, []RevokedCertificate{{}}, time.Hour)
+	assert.Error(t, err)
+	_, err = GenerateCRL(leaf.PEM(), caKey, nil, time.Hour)
+	assert.Error(t, err)
+}
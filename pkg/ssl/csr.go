@@ -15,25 +15,30 @@
 package ssl
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
+	"fmt"
+	"io"
 	"strings"
+	"unicode"
 
 	"gopkg.in/yaml.v2"
 )
 
 // CertificateName contains subject fields
 type CertificateName struct {
-	C            string `yaml:"C"`                      // Country
-	ST           string `yaml:"ST"`                     // Province
-	L            string `yaml:"L"`                      // Locality
-	O            string `yaml:"O"`                      // OrganizationName
-	OU           string `yaml:"OU,omitempty"`           // OrganizationalUnitName
-	SerialNumber string `yaml:"serialNumber,omitempty"` // SerialNumber
+	C            string `yaml:"C" json:"C"`                                           // Country
+	ST           string `yaml:"ST" json:"ST"`                                         // Province
+	L            string `yaml:"L" json:"L"`                                           // Locality
+	O            string `yaml:"O" json:"O"`                                           // OrganizationName
+	OU           string `yaml:"OU,omitempty" json:"OU,omitempty"`                     // OrganizationalUnitName
+	SerialNumber string `yaml:"serialNumber,omitempty" json:"serialNumber,omitempty"` // SerialNumber
 }
 
 func (n *CertificateName) trim() {
@@ -45,6 +50,26 @@ func (n *CertificateName) trim() {
 	n.SerialNumber = strings.TrimSpace(n.SerialNumber)
 }
 
+// Validate checks that fields which must be encoded as ASCII strings only contain ASCII characters
+func (n *CertificateName) Validate() error {
+	if !isASCII(n.C) {
+		return fmt.Errorf("country %s contains non-ASCII characters", n.C)
+	}
+	if !isASCII(n.SerialNumber) {
+		return fmt.Errorf("serial number %s contains non-ASCII characters", n.SerialNumber)
+	}
+	return nil
+}
+
+func isASCII(in string) bool {
+	for _, r := range in {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
 // Empty returns true if the certificate name is empty
 func (n *CertificateName) Empty() bool {
 	return (len(n.C) + len(n.ST) + len(n.L) + len(n.O) + len(n.OU)) == 0
@@ -52,16 +77,65 @@ func (n *CertificateName) Empty() bool {
 
 // CertificateRequest represents a certificate request
 type CertificateRequest struct {
-	Algorithm    string            `yaml:"keyAlgorithm"`           // Algorithm
-	Size         int               `yaml:"keySize,omitempty"`      // Size
-	CommonName   string            `yaml:"commonName"`             // CommonName
-	Names        []CertificateName `yaml:"names,omitempty"`        // Names
-	Hosts        []string          `yaml:"hosts,omitempty"`        // Hosts
-	SerialNumber string            `yaml:"serialNumber,omitempty"` // SerialNumber
+	Algorithm    string            `yaml:"keyAlgorithm" json:"keyAlgorithm"`                     // Algorithm
+	Size         int               `yaml:"keySize,omitempty" json:"keySize,omitempty"`           // Size
+	CommonName   string            `yaml:"commonName" json:"commonName"`                         // CommonName
+	Names        []CertificateName `yaml:"names,omitempty" json:"names,omitempty"`               // Names
+	Hosts        []string          `yaml:"hosts,omitempty" json:"hosts,omitempty"`               // Hosts
+	SerialNumber string            `yaml:"serialNumber,omitempty" json:"serialNumber,omitempty"` // SerialNumber
+
+	strict        bool
+	deterministic bool
+	sanOnly       bool
+}
+
+// CertificateRequestOption applies an option when parsing a certificate request
+type CertificateRequestOption interface {
+	Apply(csr interface{}) error
+}
+
+type strictValidationOption struct{}
+
+// WithStrictValidation optionally rejects subject fields which cannot be encoded as required by X.509
+// and email hosts which are not bare rfc822 addresses
+func WithStrictValidation() CertificateRequestOption {
+	return &strictValidationOption{}
+}
+
+// Apply applies the strictValidationOption
+func (o *strictValidationOption) Apply(csr interface{}) error {
+	c, ok := csr.(*CertificateRequest)
+	if !ok {
+		return errors.New("unexpected error")
+	}
+	c.strict = true
+	return nil
+}
+
+type sanOnlyOption struct{}
+
+// WithSANOnly permits a request with an empty subject as long as at least one host is present, the issued
+// certificate then relies entirely on its SANs. It can also be passed to GenerateCA and Generate.
+func WithSANOnly() CertificateRequestOption {
+	return &sanOnlyOption{}
 }
 
-func (csr *CertificateRequest) subject() *pkix.Name {
-	subject := &pkix.Name{}
+// Apply applies the sanOnlyOption
+func (o *sanOnlyOption) Apply(opts interface{}) error {
+	switch x := opts.(type) {
+	case *CertificateRequest:
+		x.sanOnly = true
+	case *certOptions:
+		x.sanOnly = true
+	default:
+		return errors.New("unexpected error")
+	}
+	return nil
+}
+
+// Subject returns the subject produced by merging the common name and all names
+func (csr *CertificateRequest) Subject() pkix.Name {
+	subject := pkix.Name{}
 	subject.CommonName = csr.CommonName
 
 	for _, n := range csr.Names {
@@ -74,7 +148,7 @@ func (csr *CertificateRequest) subject() *pkix.Name {
 		}
 
 		if len(n.L) > 0 {
-			subject.Province = append(subject.Locality, n.L)
+			subject.Locality = append(subject.Locality, n.L)
 		}
 
 		if len(n.O) > 0 {
@@ -90,19 +164,44 @@ func (csr *CertificateRequest) subject() *pkix.Name {
 	return subject
 }
 
-// ParseCertificateRequest parses a yaml encoded certificate request
-func ParseCertificateRequest(in []byte) (*CertificateRequest, error) {
-	var csr CertificateRequest
-	if err := yaml.Unmarshal(in, &csr); err != nil {
-		return nil, err
+type deterministicSignaturesOption struct{}
+
+// WithDeterministicSignatures optionally signs generated requests deterministically (RFC 6979 for ECDSA)
+// so that the same key and request always produce identical output. This requires go 1.24 or later.
+func WithDeterministicSignatures() CertificateRequestOption {
+	return &deterministicSignaturesOption{}
+}
+
+// Apply applies the deterministicSignaturesOption
+func (o *deterministicSignaturesOption) Apply(csr interface{}) error {
+	c, ok := csr.(*CertificateRequest)
+	if !ok {
+		return errors.New("unexpected error")
 	}
+	c.deterministic = true
+	return nil
+}
 
+func newCertificateRequest(opts ...CertificateRequestOption) (*CertificateRequest, error) {
+	csr := &CertificateRequest{}
+	for _, opt := range opts {
+		if err := opt.Apply(csr); err != nil {
+			return nil, err
+		}
+	}
+	return csr, nil
+}
+
+func (csr *CertificateRequest) validate() error {
 	if err := validateKey(csr.Algorithm, csr.Size); err != nil {
-		return nil, err
+		return err
 	}
 
 	csr.CommonName = strings.TrimSpace(csr.CommonName)
 	csr.SerialNumber = strings.TrimSpace(csr.SerialNumber)
+	if csr.strict && !isASCII(csr.SerialNumber) {
+		return fmt.Errorf("serial number %s contains non-ASCII characters", csr.SerialNumber)
+	}
 
 	names := []CertificateName{}
 	for i := range csr.Names {
@@ -110,19 +209,110 @@ func ParseCertificateRequest(in []byte) (*CertificateRequest, error) {
 		if csr.Names[i].Empty() {
 			continue
 		}
+		if csr.strict {
+			if err := csr.Names[i].Validate(); err != nil {
+				return err
+			}
+		}
 		names = append(names, csr.Names[i])
 	}
 	csr.Names = names
 
 	for i := range csr.Hosts {
 		csr.Hosts[i] = strings.TrimSpace(csr.Hosts[i])
+		if err := validateCIDRHost(csr.Hosts[i]); err != nil {
+			return err
+		}
+		if csr.strict {
+			if err := validateEmailHost(csr.Hosts[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := csr.validateDNSHosts(); err != nil {
+		return err
 	}
 
 	if csr.CommonName == "" && len(csr.Names) == 0 {
-		return nil, errors.New("no subject information provided")
+		if !csr.sanOnly {
+			return errors.New("no subject information provided")
+		}
+		if len(csr.Hosts) == 0 {
+			return errors.New("no subject or host information provided")
+		}
+	}
+
+	return nil
+}
+
+// ParseCertificateRequest parses a yaml encoded certificate request
+func ParseCertificateRequest(in []byte, opts ...CertificateRequestOption) (*CertificateRequest, error) {
+	csr, err := newCertificateRequest(opts...)
+	if err != nil {
+		return nil, err
 	}
 
-	return &csr, nil
+	if err := yaml.Unmarshal(in, csr); err != nil {
+		return nil, err
+	}
+
+	if err := csr.validate(); err != nil {
+		return nil, err
+	}
+
+	return csr, nil
+}
+
+// ParseCertificateRequests parses a stream of yaml encoded certificate requests separated by "---"
+func ParseCertificateRequests(in []byte, opts ...CertificateRequestOption) ([]*CertificateRequest, error) {
+	out := []*CertificateRequest{}
+	decoder := yaml.NewDecoder(bytes.NewReader(in))
+	for {
+		csr, err := newCertificateRequest(opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := decoder.Decode(csr); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := csr.validate(); err != nil {
+			return nil, fmt.Errorf("certificate request %d: %w", len(out)+1, err)
+		}
+		out = append(out, csr)
+	}
+	return out, nil
+}
+
+// ParsePEMCertificateRequest parses a pem encoded certificate request, such as one generated by openssl req,
+// and checks its signature
+func ParsePEMCertificateRequest(in []byte) (*x509.CertificateRequest, error) {
+	p, _ := pem.Decode(in)
+	if p == nil || (p.Type != "CERTIFICATE REQUEST" && p.Type != "NEW CERTIFICATE REQUEST") {
+		return nil, errors.New("cannot decode certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(p.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, err
+	}
+	return csr, nil
+}
+
+// Encode returns the yaml encoded certificate request
+func (csr *CertificateRequest) Encode() ([]byte, error) {
+	return yaml.Marshal(csr)
+}
+
+// EncodeJSON returns the json encoded certificate request
+func (csr *CertificateRequest) EncodeJSON() ([]byte, error) {
+	return json.Marshal(csr)
 }
 
 func (csr *CertificateRequest) generateKey() (Key, error) {
@@ -133,10 +323,19 @@ func (csr *CertificateRequest) generateKey() (Key, error) {
 	return GenerateKey(algorithm, csr.Size)
 }
 
+// random returns the source of randomness used for signatures, which is nil when signing
+// deterministically since the standard library then uses RFC 6979 nonces for ECDSA
+func (csr *CertificateRequest) random() io.Reader {
+	if csr.deterministic {
+		return nil
+	}
+	return rand.Reader
+}
+
 func (csr *CertificateRequest) generate(key Key, extensions []pkix.Extension, ExtraExtensions []pkix.Extension) ([]byte, error) {
 	hosts := csr.parseHosts()
 	template := &x509.CertificateRequest{
-		Subject:            *csr.subject(),
+		Subject:            csr.Subject(),
 		SignatureAlgorithm: key.SignatureAlgorithm(),
 		IPAddresses:        hosts.IPAddresses,
 		EmailAddresses:     hosts.EmailAddresses,
@@ -146,7 +345,7 @@ func (csr *CertificateRequest) generate(key Key, extensions []pkix.Extension, Ex
 		ExtraExtensions:    ExtraExtensions,
 	}
 
-	out, err := x509.CreateCertificateRequest(rand.Reader, template, key.PrivateKey())
+	out, err := x509.CreateCertificateRequest(csr.random(), template, key.PrivateKey())
 	if err != nil {
 		return nil, err
 	}
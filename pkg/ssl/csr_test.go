@@ -39,6 +39,49 @@ hosts:
     - admin@example.com
     - localhost
     - 10.1.0.1
+`
+	testCSRMultipleNames = `
+keyAlgorithm: ecdsa
+commonName: test.example.com
+serialNumber: "1234"
+names:
+    - C: CA
+      O: test org
+      OU: test org unit
+    - C: US
+      O: other test org
+`
+	testCSRUTF8Organization = `
+keyAlgorithm: ecdsa
+commonName: test.example.com
+names:
+    - C: CA
+      O: Société de test
+      OU: Unité
+`
+	testCSRUTF8Country = `
+keyAlgorithm: ecdsa
+commonName: test.example.com
+names:
+    - C: ÇA
+      O: test org
+`
+	testCSRMultipleDocuments = `
+keyAlgorithm: ecdsa
+commonName: first.example.com
+names:
+    - &name
+      C: CA
+      O: test org
+    - *name
+hosts:
+    - first.example.com
+---
+keyAlgorithm: rsa
+keySize: 2048
+commonName: second.example.com
+hosts:
+    - second.example.com
 `
 	testCSRMalformed  = "keyAlgorithm: rsa\n   keySize: 4096"
 	testCSRInvalidKey = "keyAlgorithm: badKey"
@@ -91,3 +134,98 @@ func TestParseCSR(t *testing.T) {
 	assert.Error(t, err)
 
 }
+
+func TestEncodeCSR(t *testing.T) {
+	csr, err := ParseCertificateRequest([]byte(testCSR))
+	if assert.NoError(t, err) {
+		encoded, err := csr.Encode()
+		if assert.NoError(t, err) {
+			decoded, err := ParseCertificateRequest(encoded)
+			if assert.NoError(t, err) {
+				assert.Equal(t, csr, decoded)
+			}
+		}
+
+		encoded, err = csr.EncodeJSON()
+		if assert.NoError(t, err) {
+			decoded, err := ParseCertificateRequest(encoded)
+			if assert.NoError(t, err) {
+				assert.Equal(t, csr, decoded)
+			}
+		}
+	}
+}
+
+func TestCSRSubject(t *testing.T) {
+	csr, err := ParseCertificateRequest([]byte(testCSRMultipleNames))
+	if assert.NoError(t, err) {
+		subject := csr.Subject()
+		assert.Equal(t, "test.example.com", subject.CommonName)
+		assert.Equal(t, "1234", subject.SerialNumber)
+		assert.Equal(t, []string{"CA", "US"}, subject.Country)
+		assert.Equal(t, []string{"test org", "other test org"}, subject.Organization)
+		assert.Equal(t, []string{"test org unit"}, subject.OrganizationalUnit)
+		assert.Empty(t, subject.Province)
+		assert.Empty(t, subject.Locality)
+	}
+}
+
+func TestCSRSubjectLocality(t *testing.T) {
+	csr, err := ParseCertificateRequest([]byte(testCSR))
+	if assert.NoError(t, err) {
+		subject := csr.Subject()
+		assert.Equal(t, []string{"QC"}, subject.Province)
+		assert.Equal(t, []string{"Montreal"}, subject.Locality)
+	}
+}
+
+func TestCSRStrictValidation(t *testing.T) {
+	csr, err := ParseCertificateRequest([]byte(testCSRUTF8Organization), WithStrictValidation())
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"Société de test"}, csr.Subject().Organization)
+	}
+
+	_, err = ParseCertificateRequest([]byte(testCSRUTF8Country))
+	assert.NoError(t, err)
+
+	_, err = ParseCertificateRequest([]byte(testCSRUTF8Country), WithStrictValidation())
+	assert.Error(t, err)
+
+	n := &CertificateName{C: "CA", SerialNumber: "número"}
+	assert.Error(t, n.Validate())
+}
+
+func TestParseCSRs(t *testing.T) {
+	csrs, err := ParseCertificateRequests([]byte(testCSRMultipleDocuments))
+	if assert.NoError(t, err) && assert.Len(t, csrs, 2) {
+		assert.Equal(t, "first.example.com", csrs[0].CommonName)
+		assert.Equal(t, "ecdsa", csrs[0].Algorithm)
+		assert.Len(t, csrs[0].Names, 2)
+		assert.Equal(t, csrs[0].Names[0], csrs[0].Names[1])
+		assert.Equal(t, "second.example.com", csrs[1].CommonName)
+		assert.Equal(t, "rsa", csrs[1].Algorithm)
+		assert.Equal(t, []string{"second.example.com"}, csrs[1].Hosts)
+	}
+
+	csrs, err = ParseCertificateRequests([]byte(testCSR))
+	if assert.NoError(t, err) {
+		assert.Len(t, csrs, 1)
+	}
+
+	_, err = ParseCertificateRequests([]byte(testCSR + "---\n" + testCSRInvalidKey))
+	assert.Error(t, err)
+}
+
+func TestDeterministicCSR(t *testing.T) {
+	csr, err := ParseCertificateRequest([]byte(testCSR), WithDeterministicSignatures())
+	if assert.NoError(t, err) {
+		key, err := csr.generateKey()
+		if assert.NoError(t, err) {
+			first, err := csr.generate(key, []pkix.Extension{}, []pkix.Extension{})
+			assert.NoError(t, err)
+			second, err := csr.generate(key, []pkix.Extension{}, []pkix.Extension{})
+			assert.NoError(t, err)
+			assert.Equal(t, first, second)
+		}
+	}
+}
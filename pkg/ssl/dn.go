@@ -0,0 +1,100 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// splitDN splits a distinguished name into its attributes on unescaped commas, unescaping values
+func splitDN(dn string) ([]string, error) {
+	out := []string{}
+	var current strings.Builder
+	for i := 0; i < len(dn); i++ {
+		switch c := dn[i]; c {
+		case ',':
+			out = append(out, current.String())
+			current.Reset()
+		case '\\':
+			if i+1 >= len(dn) {
+				return nil, errors.New("distinguished name ends with an escape character")
+			}
+			// RFC 4514 allows escaping a byte as two hex digits
+			if i+2 < len(dn) {
+				if b, err := hex.DecodeString(dn[i+1 : i+3]); err == nil {
+					current.Write(b)
+					i += 2
+					continue
+				}
+			}
+			current.WriteByte(dn[i+1])
+			i++
+		default:
+			current.WriteByte(c)
+		}
+	}
+	return append(out, current.String()), nil
+}
+
+// ParseDN parses a one line distinguished name such as CN=foo,O=bar,C=US returning the
+// name fields and the common name
+func ParseDN(dn string) (*CertificateName, string, error) {
+	attributes, err := splitDN(dn)
+	if err != nil {
+		return nil, "", err
+	}
+
+	name := &CertificateName{}
+	commonName := ""
+	seen := map[string]bool{}
+	for _, attribute := range attributes {
+		if strings.TrimSpace(attribute) == "" {
+			continue
+		}
+		parts := strings.SplitN(attribute, "=", 2)
+		if len(parts) != 2 {
+			return nil, "", fmt.Errorf("invalid attribute %s in distinguished name", attribute)
+		}
+		key, value := strings.ToUpper(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1])
+		if seen[key] {
+			return nil, "", fmt.Errorf("duplicate attribute %s in distinguished name", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "CN":
+			commonName = value
+		case "C":
+			name.C = value
+		case "ST":
+			name.ST = value
+		case "L":
+			name.L = value
+		case "O":
+			name.O = value
+		case "OU":
+			name.OU = value
+		case "SERIALNUMBER":
+			name.SerialNumber = value
+		default:
+			return nil, "", fmt.Errorf("unsupported attribute %s in distinguished name", key)
+		}
+	}
+
+	return name, commonName, nil
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDN(t *testing.T) {
+	name, cn, err := ParseDN("CN=foo,O=bar,C=US")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "foo", cn)
+		assert.Equal(t, &CertificateName{O: "bar", C: "US"}, name)
+	}
+
+	name, cn, err = ParseDN(`CN=www.example.com, O=Example\, Inc., OU=Web\2C Ops, ST=California`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "www.example.com", cn)
+		assert.Equal(t, &CertificateName{O: "Example, Inc.", OU: "Web, Ops", ST: "California"}, name)
+	}
+
+	_, _, err = ParseDN("CN=foo,DC=example")
+	assert.Error(t, err)
+	_, _, err = ParseDN("CN=foo,CN=bar")
+	assert.Error(t, err)
+	_, _, err = ParseDN("CN=foo,O")
+	assert.Error(t, err)
+	_, _, err = ParseDN(`CN=foo\`)
+	assert.Error(t, err)
+}
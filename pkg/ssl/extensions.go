@@ -0,0 +1,160 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ExtensionInfo describes a certificate extension
+type ExtensionInfo struct {
+	OID      string // OID is the dotted object identifier of the extension
+	Name     string // Name is the friendly name of a known extension, empty otherwise
+	Critical bool   // Critical is true if the extension is marked critical
+	Summary  string // Summary is a human readable description of the extension value
+}
+
+var extensionNames = map[string]string{
+	"2.5.29.14":            "SubjectKeyIdentifier",
+	"2.5.29.15":            "KeyUsage",
+	"2.5.29.17":            "SubjectAltName",
+	"2.5.29.19":            "BasicConstraints",
+	"2.5.29.30":            "NameConstraints",
+	"2.5.29.31":            "CRLDistributionPoints",
+	"2.5.29.32":            "CertificatePolicies",
+	"2.5.29.35":            "AuthorityKeyIdentifier",
+	"2.5.29.37":            "ExtKeyUsage",
+	"1.3.6.1.5.5.7.1.1":    "AuthorityInfoAccess",
+	"1.3.6.1.5.5.7.48.1.5": "OCSPNoCheck",
+}
+
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digital signature"},
+	{x509.KeyUsageContentCommitment, "content commitment"},
+	{x509.KeyUsageKeyEncipherment, "key encipherment"},
+	{x509.KeyUsageDataEncipherment, "data encipherment"},
+	{x509.KeyUsageKeyAgreement, "key agreement"},
+	{x509.KeyUsageCertSign, "cert sign"},
+	{x509.KeyUsageCRLSign, "crl sign"},
+	{x509.KeyUsageEncipherOnly, "encipher only"},
+	{x509.KeyUsageDecipherOnly, "decipher only"},
+}
+
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                        "any",
+	x509.ExtKeyUsageServerAuth:                 "server auth",
+	x509.ExtKeyUsageClientAuth:                 "client auth",
+	x509.ExtKeyUsageCodeSigning:                "code signing",
+	x509.ExtKeyUsageEmailProtection:            "email protection",
+	x509.ExtKeyUsageIPSECEndSystem:             "ipsec end system",
+	x509.ExtKeyUsageIPSECTunnel:                "ipsec tunnel",
+	x509.ExtKeyUsageIPSECUser:                  "ipsec user",
+	x509.ExtKeyUsageTimeStamping:               "timestamping",
+	x509.ExtKeyUsageOCSPSigning:                "ocsp signing",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto: "microsoft sgc",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:  "netscape sgc",
+}
+
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = fmt.Sprintf("%02X", c)
+	}
+	return strings.Join(parts, ":")
+}
+
+func extensionSummary(cert *x509.Certificate, oid string, value []byte) string {
+	parts := []string{}
+	switch oid {
+	case "2.5.29.14":
+		return colonHex(cert.SubjectKeyId)
+	case "2.5.29.35":
+		return colonHex(cert.AuthorityKeyId)
+	case "2.5.29.19":
+		summary := fmt.Sprintf("CA:%t", cert.IsCA)
+		if cert.MaxPathLen > 0 || cert.MaxPathLenZero {
+			summary += fmt.Sprintf(", pathlen:%d", cert.MaxPathLen)
+		}
+		return summary
+	case "2.5.29.15":
+		for _, u := range keyUsageNames {
+			if cert.KeyUsage&u.usage != 0 {
+				parts = append(parts, u.name)
+			}
+		}
+	case "2.5.29.37":
+		for _, u := range cert.ExtKeyUsage {
+			if name, ok := extKeyUsageNames[u]; ok {
+				parts = append(parts, name)
+			}
+		}
+		for _, u := range cert.UnknownExtKeyUsage {
+			parts = append(parts, u.String())
+		}
+	case "2.5.29.17":
+		for _, name := range cert.DNSNames {
+			parts = append(parts, "DNS:"+name)
+		}
+		for _, ip := range cert.IPAddresses {
+			parts = append(parts, "IP:"+ip.String())
+		}
+		for _, email := range cert.EmailAddresses {
+			parts = append(parts, "email:"+email)
+		}
+		for _, uri := range cert.URIs {
+			parts = append(parts, "URI:"+uri.String())
+		}
+	case "2.5.29.31":
+		for _, dp := range cert.CRLDistributionPoints {
+			parts = append(parts, "URI:"+dp)
+		}
+	case "1.3.6.1.5.5.7.1.1":
+		for _, server := range cert.OCSPServer {
+			parts = append(parts, "OCSP:"+server)
+		}
+		for _, issuer := range cert.IssuingCertificateURL {
+			parts = append(parts, "CA Issuers:"+issuer)
+		}
+	default:
+		return hex.EncodeToString(value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CertificateExtensions lists the extensions of a pem encoded certificate in the order they appear
+func CertificateExtensions(certPEM []byte) ([]ExtensionInfo, error) {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]ExtensionInfo, 0, len(cert.Extensions))
+	for _, ext := range cert.Extensions {
+		oid := ext.Id.String()
+		out = append(out, ExtensionInfo{
+			OID:      oid,
+			Name:     extensionNames[oid],
+			Critical: ext.Critical,
+			Summary:  extensionSummary(cert, oid, ext.Value),
+		})
+	}
+	return out, nil
+}
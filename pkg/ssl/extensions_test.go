@@ -0,0 +1,78 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertificateExtensions(t *testing.T) {
+	ca, caKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	leaf, _, err := Generate([]byte(testCSR), ca, caKey, time.Hour, UsagesForProfile(WebServer))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	extensions, err := CertificateExtensions(leaf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	byName := map[string]ExtensionInfo{}
+	for _, ext := range extensions {
+		assert.NotEmpty(t, ext.Name, ext.OID)
+		byName[ext.Name] = ext
+	}
+
+	expected := map[string]struct {
+		critical bool
+		summary  string
+	}{
+		"KeyUsage":         {true, "digital signature, key encipherment"},
+		"ExtKeyUsage":      {false, "server auth"},
+		"BasicConstraints": {true, "CA:false"},
+		"SubjectAltName":   {false, "DNS:example.com, DNS:localhost, IP:10.1.0.1, email:admin@example.com"},
+	}
+	for name, e := range expected {
+		ext, ok := byName[name]
+		if assert.True(t, ok, name) {
+			assert.Equal(t, e.critical, ext.Critical, name)
+			assert.Equal(t, e.summary, ext.Summary, name)
+		}
+	}
+
+	extensions, err = CertificateExtensions(ca)
+	if assert.NoError(t, err) {
+		names := map[string]ExtensionInfo{}
+		for _, ext := range extensions {
+			names[ext.Name] = ext
+		}
+		assert.Equal(t, "CA:true", names["BasicConstraints"].Summary)
+		assert.Equal(t, "cert sign, crl sign", names["KeyUsage"].Summary)
+		if ext, ok := names["SubjectKeyIdentifier"]; assert.True(t, ok) {
+			assert.Equal(t, "2.5.29.14", ext.OID)
+			assert.False(t, ext.Critical)
+			assert.Regexp(t, `^[0-9A-F]{2}(:[0-9A-F]{2})+$`, ext.Summary)
+		}
+	}
+
+	_, err = CertificateExtensions([]byte("not a certificate"))
+	assert.Error(t, err)
+}
@@ -15,11 +15,19 @@
 package ssl
 
 import (
+	"fmt"
 	"net"
 	"net/mail"
 	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/idna"
 )
 
+// maxCIDRAddresses is the largest CIDR range which can be expanded into ip address SANs
+const maxCIDRAddresses = 256
+
 type certificateHosts struct {
 	DNSNames       []string
 	EmailAddresses []string
@@ -35,11 +43,16 @@ func (csr *CertificateRequest) parseHosts() *certificateHosts {
 		URIs:           []*url.URL{},
 	}
 	for _, host := range csr.Hosts {
-		if ip := net.ParseIP(host); ip != nil {
+		if ip := parseIPHost(host); ip != nil {
 			out.IPAddresses = append(out.IPAddresses, ip)
 			continue
 		}
 
+		if _, network, err := net.ParseCIDR(host); err == nil {
+			out.IPAddresses = append(out.IPAddresses, expandCIDR(network)...)
+			continue
+		}
+
 		if email, err := mail.ParseAddress(host); err == nil && email != nil {
 			out.EmailAddresses = append(out.EmailAddresses, email.Address)
 			continue
@@ -50,8 +63,110 @@ func (csr *CertificateRequest) parseHosts() *certificateHosts {
 			continue
 		}
 
+		if !isASCII(host) {
+			if ascii, err := toASCIIHost(host); err == nil {
+				host = ascii
+			}
+		}
 		out.DNSNames = append(out.DNSNames, host)
 	}
 
 	return out
 }
+
+// expandCIDR returns every address in the network, which must have been checked by validateCIDRHost
+func expandCIDR(network *net.IPNet) []net.IP {
+	ones, bits := network.Mask.Size()
+	count := 1 << uint(bits-ones)
+	out := make([]net.IP, 0, count)
+	ip := append(net.IP{}, network.IP...)
+	for i := 0; i < count; i++ {
+		out = append(out, append(net.IP{}, ip...))
+		for j := len(ip) - 1; j >= 0; j-- {
+			ip[j]++
+			if ip[j] != 0 {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// validateCIDRHost checks that a host which parses as a CIDR range is small enough to be expanded
+func validateCIDRHost(host string) error {
+	_, network, err := net.ParseCIDR(host)
+	if err != nil {
+		return nil
+	}
+	ones, bits := network.Mask.Size()
+	if bits-ones > 8 || 1<<uint(bits-ones) > maxCIDRAddresses {
+		return fmt.Errorf("cidr host %s is larger than %d addresses", host, maxCIDRAddresses)
+	}
+	return nil
+}
+
+// parseIPHost parses an ip address host, stripping the zone from scoped IPv6 addresses such as fe80::1%eth0
+// since zones cannot be encoded in an ip address SAN
+func parseIPHost(host string) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+	i := strings.LastIndexByte(host, '%')
+	if i < 0 {
+		return nil
+	}
+	ip := net.ParseIP(host[:i])
+	if ip == nil || ip.To4() != nil {
+		return nil
+	}
+	log.Warn().Msgf("stripping zone %s from ip address host %s", host[i+1:], host)
+	return ip
+}
+
+// toASCIIHost converts a dns name to its punycode form, a wildcard is only allowed as the leftmost label.
+// ASCII names are returned as is so names such as _acme-challenge.example.com remain valid.
+func toASCIIHost(host string) (string, error) {
+	name := strings.TrimPrefix(host, "*.")
+	if strings.Contains(name, "*") {
+		return "", fmt.Errorf("dns host %s may only contain a wildcard as the leftmost label", host)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return "", fmt.Errorf("dns host %s contains an empty label", host)
+		}
+	}
+	if isASCII(name) {
+		return host, nil
+	}
+	ascii, err := idna.Lookup.ToASCII(name)
+	if err != nil {
+		return "", fmt.Errorf("invalid dns host %s: %w", host, err)
+	}
+	if name != host {
+		ascii = "*." + ascii
+	}
+	return ascii, nil
+}
+
+// validateDNSHosts checks that the hosts which will be encoded as dns names are valid
+func (csr *CertificateRequest) validateDNSHosts() error {
+	for _, name := range csr.parseHosts().DNSNames {
+		if _, err := toASCIIHost(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateEmailHost checks that a host which parses as an email address is a bare local@domain
+// address as required for an rfc822Name, rejecting display name forms such as "Admin" <admin@example.com>
+func validateEmailHost(host string) error {
+	email, err := mail.ParseAddress(host)
+	if err != nil || email == nil {
+		return nil
+	}
+	if email.Name != "" || email.Address != host {
+		return fmt.Errorf("email host %s must be a bare address without a display name", host)
+	}
+	return nil
+}
@@ -40,3 +40,80 @@ func TestParseHosts(t *testing.T) {
 	uri, _ := url.ParseRequestURI("https://example.com")
 	assert.Equal(t, *uri, *hosts.URIs[0])
 }
+
+func TestStrictEmailHosts(t *testing.T) {
+	assert.NoError(t, validateEmailHost("admin@example.com"))
+	assert.NoError(t, validateEmailHost("example.com"))
+	assert.Error(t, validateEmailHost(`"Admin" <admin@example.com>`))
+	assert.Error(t, validateEmailHost("<admin@example.com>"))
+
+	csr := `
+keyAlgorithm: ecdsa
+commonName: admin
+hosts:
+    - '"Admin" <admin@example.com>'
+`
+	parsed, err := ParseCertificateRequest([]byte(csr))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"admin@example.com"}, parsed.parseHosts().EmailAddresses)
+	}
+
+	_, err = ParseCertificateRequest([]byte(csr), WithStrictValidation())
+	assert.Error(t, err)
+}
+
+func TestParseHostsIPv6Zone(t *testing.T) {
+	csr := &CertificateRequest{Hosts: []string{"fe80::1%eth0", "10.1.0.1%eth0"}}
+	hosts := csr.parseHosts()
+	assert.Equal(t, []net.IP{net.ParseIP("fe80::1")}, hosts.IPAddresses)
+	assert.Equal(t, []string{"10.1.0.1%eth0"}, hosts.DNSNames)
+}
+
+func TestParseHostsCIDR(t *testing.T) {
+	csr := &CertificateRequest{
+		Hosts: []string{"10.1.0.1", "192.168.0.0/30", "example.com", "admin@example.com", "fd00::/127"},
+	}
+	hosts := csr.parseHosts()
+	assert.Equal(t, []net.IP{
+		net.ParseIP("10.1.0.1"),
+		net.ParseIP("192.168.0.0").To4(),
+		net.ParseIP("192.168.0.1").To4(),
+		net.ParseIP("192.168.0.2").To4(),
+		net.ParseIP("192.168.0.3").To4(),
+		net.ParseIP("fd00::"),
+		net.ParseIP("fd00::1"),
+	}, hosts.IPAddresses)
+	assert.Equal(t, []string{"example.com"}, hosts.DNSNames)
+	assert.Equal(t, []string{"admin@example.com"}, hosts.EmailAddresses)
+	assert.Empty(t, hosts.URIs)
+
+	assert.NoError(t, validateCIDRHost("10.1.0.0/24"))
+	assert.NoError(t, validateCIDRHost("example.com"))
+	assert.Error(t, validateCIDRHost("10.1.0.0/23"))
+	assert.Error(t, validateCIDRHost("fd00::/64"))
+
+	_, err := ParseCertificateRequest([]byte("keyAlgorithm: ecdsa\ncommonName: test\nhosts:\n    - 10.0.0.0/8\n"))
+	assert.Error(t, err)
+}
+
+func TestParseHostsInternationalized(t *testing.T) {
+	csr := &CertificateRequest{Hosts: []string{"例え.jp", "*.例え.jp", "*.example.com", "Example.com"}}
+	hosts := csr.parseHosts()
+	assert.Equal(t, []string{"xn--r8jz45g.jp", "*.xn--r8jz45g.jp", "*.example.com", "Example.com"}, hosts.DNSNames)
+	assert.NoError(t, csr.validateDNSHosts())
+
+	for _, host := range []string{"foo.*.example.com", "foo*.example.com", "a..example.com", "-例え.jp", "例え host.jp"} {
+		csr := &CertificateRequest{Hosts: []string{host}}
+		assert.Error(t, csr.validateDNSHosts(), host)
+	}
+
+	_, err := ParseCertificateRequest([]byte("keyAlgorithm: ecdsa\ncommonName: test\nhosts:\n    - www.*.example.com\n"))
+	assert.Error(t, err)
+
+	// ascii names are not subject to the idna rules which disallow underscores
+	csr = &CertificateRequest{Hosts: []string{"_acme-challenge.example.com", "my_host.internal"}}
+	assert.Equal(t, []string{"_acme-challenge.example.com", "my_host.internal"}, csr.parseHosts().DNSNames)
+	assert.NoError(t, csr.validateDNSHosts())
+	_, err = ParseCertificateRequest([]byte("keyAlgorithm: ecdsa\ncommonName: test\nhosts:\n    - _acme-challenge.example.com\n"))
+	assert.NoError(t, err)
+}
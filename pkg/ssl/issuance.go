@@ -0,0 +1,53 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var issuanceLogLock sync.Mutex
+
+// AppendIssuanceLog appends the serial number, expiry and subject of a pem encoded certificate to an issuance log,
+// the log is locked while appending so concurrent writers in other processes do not interleave lines
+func AppendIssuanceLog(path string, certPEM []byte) error {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%s\t%s\t%s\n", cert.SerialNumber.Text(16), cert.NotAfter.UTC().Format(time.RFC3339), cert.Subject.String())
+
+	issuanceLogLock.Lock()
+	defer issuanceLogLock.Unlock()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+
+	if _, err := f.WriteString(line); err != nil {
+		return err
+	}
+	return f.Sync()
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendIssuanceLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issuance")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "issued.log")
+
+	caCert, caKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := 0; i < 2; i++ {
+		cert, _, err := Generate([]byte(testCSR), caCert, caKey, DefaultCertificateExpiration, UsagesForProfile(WebServer))
+		if assert.NoError(t, err) {
+			assert.NoError(t, AppendIssuanceLog(path, cert))
+		}
+	}
+
+	out, err := ioutil.ReadFile(path)
+	if assert.NoError(t, err) {
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		if assert.Len(t, lines, 2) {
+			for _, line := range lines {
+				fields := strings.Split(line, "\t")
+				if assert.Len(t, fields, 3) {
+					assert.Contains(t, fields[2], "CN=test.example.com")
+				}
+			}
+			assert.NotEqual(t, lines[0], lines[1])
+		}
+	}
+
+	assert.Error(t, AppendIssuanceLog(path, []byte("not a certificate")))
+}
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -28,6 +29,7 @@ import (
 	"io"
 
 	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/ssh"
 )
 
 const (
@@ -44,6 +46,8 @@ const (
 	ECDSAKey
 	// RSAKey specifies an RSA key
 	RSAKey
+	// Ed25519Key specifies an Ed25519 key
+	Ed25519Key
 )
 
 // ParseKeyAlgorithm parses a key algorithm
@@ -53,6 +57,8 @@ func ParseKeyAlgorithm(in string) (KeyAlgorithm, error) {
 		return ECDSAKey, nil
 	case "rsa":
 		return RSAKey, nil
+	case "ed25519":
+		return Ed25519Key, nil
 	default:
 		return keyAlgorithmNotSet, fmt.Errorf("unknown key type: %s", in)
 	}
@@ -64,6 +70,8 @@ func (a KeyAlgorithm) String() string {
 		return "ecdsa"
 	case RSAKey:
 		return "rsa"
+	case Ed25519Key:
+		return "ed25519"
 	}
 	log.Panic().Msg("unexpected key algorithm")
 	return ""
@@ -76,6 +84,8 @@ func (a KeyAlgorithm) DefaultSize() int {
 		return 256
 	case RSAKey:
 		return 4096
+	case Ed25519Key:
+		return 0 // Ed25519 keys have a fixed size
 	}
 	log.Panic().Msg("unexpected key algorithm")
 	return 0
@@ -94,6 +104,11 @@ func (a KeyAlgorithm) ValidKeySize(size int) error {
 			return fmt.Errorf("invalid rsa key size %d - key size must be between %d and %d", size, minRSAKeySize, maxRSAKeySize)
 		}
 		return nil
+	case Ed25519Key:
+		if size != 0 {
+			return fmt.Errorf("invalid ed25519 key size %d - ed25519 keys have a fixed size so it must be 0", size)
+		}
+		return nil
 	}
 
 	log.Panic().Msg("unexpected key algorithm")
@@ -109,6 +124,9 @@ type Key interface {
 	PublicKeyAlgorithm() x509.PublicKeyAlgorithm
 	PublicKey() crypto.PublicKey
 	SignatureAlgorithm() x509.SignatureAlgorithm
+	// RawPublicKey returns the uncompressed point for ECDSA keys, the modulus for RSA keys
+	// and the 32 byte public key for Ed25519 keys
+	RawPublicKey() ([]byte, error)
 
 	AsReader() io.Reader
 }
@@ -146,12 +164,26 @@ func (k *baseKey) PublicKey() crypto.PublicKey {
 		return pub.Public()
 	case *rsa.PrivateKey:
 		return pub.Public()
+	case ed25519.PrivateKey:
+		return pub.Public()
 	default:
 		log.Panic().Msg("unexpected key algorithm")
 		return nil
 	}
 }
 
+func (k *baseKey) RawPublicKey() ([]byte, error) {
+	switch priv := k.privateKey.(type) {
+	case *ecdsa.PrivateKey:
+		return elliptic.Marshal(priv.Curve, priv.X, priv.Y), nil
+	case *rsa.PrivateKey:
+		return priv.N.Bytes(), nil
+	case ed25519.PrivateKey:
+		return []byte(priv.Public().(ed25519.PublicKey)), nil
+	}
+	return nil, errors.New("unsupported public key type")
+}
+
 func validateKey(algorithm string, size int) error {
 	a, err := ParseKeyAlgorithm(algorithm)
 	if err != nil {
@@ -174,12 +206,35 @@ func GenerateKey(algorithm KeyAlgorithm, size int) (Key, error) {
 		return generateECDSAKey(size)
 	case RSAKey:
 		return generateRSAKey(size)
+	case Ed25519Key:
+		return generateEd25519Key()
 	default:
 		log.Panic().Msg("unexpected key algorithm")
 		return nil, nil
 	}
 }
 
+// GenerateEncryptedKey generates a new key which is pem encoded as a PKCS #8 private key encrypted
+// with passphrase using PBES2 and AES-256-CBC
+func GenerateEncryptedKey(algorithm KeyAlgorithm, size int, passphrase []byte) (Key, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase cannot be empty")
+	}
+	key, err := GenerateKey(algorithm, size)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := encryptPKCS8(der, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return parsePrivateKeyWithPassphrase(pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encrypted}), passphrase)
+}
+
 type ecdsaKey struct {
 	baseKey
 }
@@ -284,6 +339,41 @@ func generateRSAKey(size int) (*rsaKey, error) {
 	return out, nil
 }
 
+type ed25519Key struct {
+	baseKey
+}
+
+func (k *ed25519Key) PublicKeyAlgorithm() x509.PublicKeyAlgorithm {
+	return x509.Ed25519
+}
+
+func (k *ed25519Key) SignatureAlgorithm() x509.SignatureAlgorithm {
+	return x509.PureEd25519
+}
+
+func newEd25519Key(key ed25519.PrivateKey, encoded []byte) *ed25519Key {
+	out := &ed25519Key{}
+	out.algorithm = Ed25519Key
+	out.size = Ed25519Key.DefaultSize()
+	out.encoded = encoded
+	out.privateKey = key
+	return out
+}
+
+func generateEd25519Key() (*ed25519Key, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEd25519Key(key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encoded})), nil
+}
+
 func getCurveSize(c elliptic.Curve) int {
 	if c == elliptic.P256() {
 		return 256
@@ -297,10 +387,22 @@ func getCurveSize(c elliptic.Curve) int {
 	return ECDSAKey.DefaultSize()
 }
 
-func parsePrivateKey(keyPEM []byte) (Key, error) {
-	p, _ := pem.Decode(keyPEM)
-	keyDER := p.Bytes
+func parsePrivateKeyBlock(p *pem.Block, keyPEM, passphrase []byte) (interface{}, error) {
+	if p.Type == "OPENSSH PRIVATE KEY" {
+		return ssh.ParseRawPrivateKey(keyPEM)
+	}
+	if p.Type == "ENCRYPTED PRIVATE KEY" {
+		if passphrase == nil {
+			return nil, errors.New("private key is encrypted - a passphrase is required")
+		}
+		der, err := decryptPKCS8(p.Bytes, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return x509.ParsePKCS8PrivateKey(der)
+	}
 
+	keyDER := p.Bytes
 	key, err := x509.ParsePKCS8PrivateKey(keyDER)
 	if err != nil {
 		key, err = x509.ParsePKCS1PrivateKey(keyDER)
@@ -311,12 +413,44 @@ func parsePrivateKey(keyPEM []byte) (Key, error) {
 			}
 		}
 	}
+	return key, nil
+}
+
+// ParseKey parses a pem encoded private key such as one previously generated by GenerateKey
+func ParseKey(pemBytes []byte) (Key, error) {
+	return parsePrivateKey(pemBytes)
+}
+
+// ParseEncryptedKey parses a pem encoded private key such as one previously generated by GenerateEncryptedKey,
+// decrypting it with passphrase. Unencrypted keys are parsed as by ParseKey.
+func ParseEncryptedKey(pemBytes, passphrase []byte) (Key, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase cannot be empty")
+	}
+	return parsePrivateKeyWithPassphrase(pemBytes, passphrase)
+}
+
+func parsePrivateKey(keyPEM []byte) (Key, error) {
+	return parsePrivateKeyWithPassphrase(keyPEM, nil)
+}
+
+// parsePrivateKeyWithPassphrase parses a pem encoded private key decrypting it with passphrase if it is encrypted
+func parsePrivateKeyWithPassphrase(keyPEM, passphrase []byte) (Key, error) {
+	p, _ := pem.Decode(keyPEM)
+	if p == nil {
+		return nil, errors.New("cannot decode private key")
+	}
+
+	key, err := parsePrivateKeyBlock(p, keyPEM, passphrase)
+	if err != nil {
+		return nil, err
+	}
 
 	switch priv := key.(type) {
 	case *rsa.PrivateKey:
 		out := &rsaKey{}
 		out.algorithm = RSAKey
-		out.size = priv.Size()
+		out.size = priv.N.BitLen()
 		out.encoded = keyPEM
 		out.privateKey = priv
 		return out, nil
@@ -327,6 +461,10 @@ func parsePrivateKey(keyPEM []byte) (Key, error) {
 		out.encoded = keyPEM
 		out.privateKey = priv
 		return out, nil
+	case ed25519.PrivateKey:
+		return newEd25519Key(priv, keyPEM), nil
+	case *ed25519.PrivateKey:
+		return newEd25519Key(*priv, keyPEM), nil
 	}
 
 	return nil, errors.New("unknown private key type")
@@ -15,9 +15,16 @@
 package ssl
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
 	"io/ioutil"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -86,3 +93,246 @@ func TestKeyFunctions(t *testing.T) {
 		assert.NoError(t, ioutil.WriteFile(filepath.Join("../../testdata", "test-ec-key.pem"), key.Encoded(), 0644))
 	}
 }
+
+func TestParseOpenSSHKey(t *testing.T) {
+	keyPEM, err := ioutil.ReadFile(filepath.Join("../../testdata", "openssh-ecdsa-key"))
+	if assert.NoError(t, err) {
+		key, err := parsePrivateKey(keyPEM)
+		if assert.NoError(t, err) {
+			assert.Equal(t, ECDSAKey, key.Algorithm())
+			assert.Equal(t, 256, key.Size())
+			assert.Equal(t, keyPEM, key.Encoded())
+			pub, ok := key.PublicKey().(*ecdsa.PublicKey)
+			if assert.True(t, ok) {
+				assert.Equal(t, elliptic.P256(), pub.Curve)
+			}
+		}
+	}
+}
+
+func TestEd25519Key(t *testing.T) {
+	a, err := ParseKeyAlgorithm("ed25519")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, Ed25519Key, a)
+	assert.Equal(t, "ed25519", a.String())
+	assert.Equal(t, 0, a.DefaultSize())
+	assert.NoError(t, a.ValidKeySize(0))
+	assert.Error(t, a.ValidKeySize(256))
+
+	key, err := GenerateKey(Ed25519Key, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, Ed25519Key, key.Algorithm())
+	assert.Equal(t, x509.Ed25519, key.PublicKeyAlgorithm())
+	assert.Equal(t, x509.PureEd25519, key.SignatureAlgorithm())
+	_, ok := key.PublicKey().(ed25519.PublicKey)
+	assert.True(t, ok)
+
+	parsed, err := parsePrivateKey(key.Encoded())
+	if assert.NoError(t, err) {
+		assert.Equal(t, Ed25519Key, parsed.Algorithm())
+		assert.Equal(t, key.PrivateKey(), parsed.PrivateKey())
+		assert.Equal(t, key.Encoded(), parsed.Encoded())
+	}
+
+	csr := `
+keyAlgorithm: ed25519
+commonName: ed25519.example.com
+hosts:
+    - ed25519.example.com
+`
+	caCert, caKey, err := GenerateCA([]byte(csr), DefaultCertificateExpiration)
+	if assert.NoError(t, err) {
+		_, _, err = Generate([]byte(csr), caCert, caKey, DefaultCertificateExpiration, UsagesForProfile(WebServer))
+		assert.NoError(t, err)
+	}
+}
+
+func TestEncryptedKey(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+	for _, algorithm := range []KeyAlgorithm{ECDSAKey, RSAKey, Ed25519Key} {
+		size := 0
+		if algorithm == RSAKey {
+			size = 2048
+		}
+		key, err := GenerateEncryptedKey(algorithm, size, passphrase)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		p, _ := pem.Decode(key.Encoded())
+		if assert.NotNil(t, p) {
+			assert.Equal(t, "ENCRYPTED PRIVATE KEY", p.Type)
+		}
+
+		parsed, err := ParseEncryptedKey(key.Encoded(), passphrase)
+		if assert.NoError(t, err) {
+			assert.Equal(t, algorithm, parsed.Algorithm())
+			assert.Equal(t, key.PrivateKey(), parsed.PrivateKey())
+		}
+
+		_, err = ParseEncryptedKey(key.Encoded(), []byte("wrong passphrase"))
+		assert.Error(t, err)
+		_, err = ParseEncryptedKey(key.Encoded(), nil)
+		assert.Error(t, err)
+		_, err = ParseKey(key.Encoded())
+		assert.Error(t, err)
+	}
+
+	_, err := GenerateEncryptedKey(ECDSAKey, 0, nil)
+	assert.Error(t, err)
+
+	plain, err := GenerateKey(ECDSAKey, 0)
+	if assert.NoError(t, err) {
+		parsed, err := ParseEncryptedKey(plain.Encoded(), passphrase)
+		if assert.NoError(t, err) {
+			assert.Equal(t, plain.PrivateKey(), parsed.PrivateKey())
+		}
+	}
+}
+
+func TestEncryptedKeyIterations(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+	key, err := GenerateKey(ECDSAKey, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey())
+	if !assert.NoError(t, err) {
+		return
+	}
+	encrypted, err := encryptPKCS8(der, passphrase)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// rewrite the iteration count of the key derivation function
+	withIterations := func(n int) []byte {
+		var info encryptedPrivateKeyInfo
+		var params pbes2Params
+		var kdfParams pbkdf2Params
+		_, err := asn1.Unmarshal(encrypted, &info)
+		assert.NoError(t, err)
+		_, err = asn1.Unmarshal(info.EncryptionAlgorithm.Parameters.FullBytes, &params)
+		assert.NoError(t, err)
+		_, err = asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams)
+		assert.NoError(t, err)
+		kdfParams.IterationCount = n
+		params.KeyDerivationFunc.Parameters.FullBytes, err = asn1.Marshal(kdfParams)
+		assert.NoError(t, err)
+		info.EncryptionAlgorithm.Parameters.FullBytes, err = asn1.Marshal(params)
+		assert.NoError(t, err)
+		out, err := asn1.Marshal(info)
+		assert.NoError(t, err)
+		return out
+	}
+
+	_, err = decryptPKCS8(withIterations(pkcs8Iterations), passphrase)
+	assert.NoError(t, err)
+	for _, n := range []int{0, pkcs8MaxIterations + 1, 1 << 30} {
+		_, err = decryptPKCS8(withIterations(n), passphrase)
+		if assert.Error(t, err, "%d iterations", n) {
+			assert.Contains(t, err.Error(), "iteration count")
+		}
+	}
+}
+
+func TestEncryptedCAKey(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+	caCert, caKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	key, err := ParseKey(caKey)
+	if !assert.NoError(t, err) {
+		return
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey())
+	if !assert.NoError(t, err) {
+		return
+	}
+	encrypted, err := encryptPKCS8(der, passphrase)
+	if !assert.NoError(t, err) {
+		return
+	}
+	encryptedKey := pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encrypted})
+
+	_, _, err = Generate([]byte(testCSR), caCert, encryptedKey, time.Hour, UsagesForProfile(WebServer))
+	assert.Error(t, err)
+	cert, _, err := Generate([]byte(testCSR), caCert, encryptedKey, time.Hour, UsagesForProfile(WebServer), WithCAKeyPassphrase(passphrase))
+	if assert.NoError(t, err) {
+		leaf, err := parseCertificate(cert)
+		ca, _ := parseCertificate(caCert)
+		if assert.NoError(t, err) {
+			assert.NoError(t, leaf.CheckSignatureFrom(ca))
+		}
+	}
+
+	_, err = GenerateCRL(caCert, encryptedKey, nil, 0)
+	assert.Error(t, err)
+	_, err = GenerateCRL(caCert, encryptedKey, nil, 0, WithCAKeyPassphrase(passphrase))
+	assert.NoError(t, err)
+
+	_, err = newCertOptions(WithCAKeyPassphrase(nil))
+	assert.Error(t, err)
+}
+
+func TestRawPublicKey(t *testing.T) {
+	key, err := GenerateKey(ECDSAKey, 256)
+	if assert.NoError(t, err) {
+		raw, err := key.RawPublicKey()
+		if assert.NoError(t, err) && assert.Len(t, raw, 65) {
+			assert.Equal(t, byte(0x04), raw[0])
+			pub := key.PublicKey().(*ecdsa.PublicKey)
+			assert.Equal(t, pub.X.FillBytes(make([]byte, 32)), raw[1:33])
+			assert.Equal(t, pub.Y.FillBytes(make([]byte, 32)), raw[33:])
+		}
+	}
+
+	key, err = GenerateKey(Ed25519Key, 0)
+	if assert.NoError(t, err) {
+		raw, err := key.RawPublicKey()
+		if assert.NoError(t, err) && assert.Len(t, raw, 32) {
+			assert.Equal(t, []byte(key.PublicKey().(ed25519.PublicKey)), raw)
+		}
+	}
+
+	key, err = GenerateKey(RSAKey, 2048)
+	if assert.NoError(t, err) {
+		raw, err := key.RawPublicKey()
+		if assert.NoError(t, err) {
+			assert.Len(t, raw, 256)
+		}
+	}
+
+	_, err = (&baseKey{}).RawPublicKey()
+	assert.Error(t, err)
+}
+
+func TestParseKey(t *testing.T) {
+	for _, algorithm := range []KeyAlgorithm{RSAKey, ECDSAKey} {
+		size := 0
+		if algorithm == RSAKey {
+			size = 2048
+		}
+		key, err := GenerateKey(algorithm, size)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		parsed, err := ParseKey(key.Encoded())
+		if assert.NoError(t, err) {
+			assert.Equal(t, algorithm, parsed.Algorithm())
+			assert.Equal(t, key.PrivateKey(), parsed.PrivateKey())
+			assert.Equal(t, key.SignatureAlgorithm(), parsed.SignatureAlgorithm())
+		}
+	}
+
+	_, err := ParseKey([]byte("not a pem encoded key"))
+	assert.Error(t, err)
+	_, err = ParseKey(nil)
+	assert.Error(t, err)
+	_, err = ParseKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte("garbage")}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"errors"
+	"sync"
+)
+
+// KeyCache pre-generates keys of a given algorithm and size in the background
+// to speed up bulk issuance and tests
+type KeyCache struct {
+	algorithm KeyAlgorithm
+	size      int
+
+	keys chan Key
+	done chan struct{}
+	once sync.Once
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewKeyCache returns a KeyCache which keeps up to capacity keys ready. Close should be called
+// once the cache is no longer needed to stop generating keys.
+func NewKeyCache(alg KeyAlgorithm, size, capacity int) *KeyCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	c := &KeyCache{
+		algorithm: alg,
+		size:      size,
+		keys:      make(chan Key, capacity),
+		done:      make(chan struct{}),
+	}
+	go c.generate()
+	return c
+}
+
+func (c *KeyCache) generate() {
+	defer close(c.keys)
+	for {
+		key, err := GenerateKey(c.algorithm, c.size)
+		if err != nil {
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+			return
+		}
+		select {
+		case c.keys <- key:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Get returns a pre-generated key waiting for one to be generated if the cache is empty
+func (c *KeyCache) Get() (Key, error) {
+	if key, ok := <-c.keys; ok {
+		return key, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	return nil, errors.New("key cache is closed")
+}
+
+// Close stops generating keys
+func (c *KeyCache) Close() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
@@ -0,0 +1,42 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyCache(t *testing.T) {
+	cache := NewKeyCache(ECDSAKey, 384, 2)
+	defer cache.Close()
+
+	seen := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		key, err := cache.Get()
+		if assert.NoError(t, err) {
+			assert.Equal(t, ECDSAKey, key.Algorithm())
+			assert.Equal(t, 384, key.Size())
+			assert.False(t, seen[string(key.Encoded())], "keys should not be handed out twice")
+			seen[string(key.Encoded())] = true
+		}
+	}
+
+	invalid := NewKeyCache(ECDSAKey, 123, 2)
+	defer invalid.Close()
+	_, err := invalid.Get()
+	assert.Error(t, err)
+}
@@ -0,0 +1,274 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"hash"
+	"math/big"
+	"unicode/utf16"
+)
+
+// PKCS #12 bundles (RFC 7292) are written as by OpenSSL 3, the private key is shrouded with PBES2 and the
+// bundle is authenticated with an HMAC-SHA256 mac
+
+const (
+	pkcs12MacSaltSize   = 8
+	pkcs12MacIterations = 2048
+	pkcs12MacKeyID      = 3
+)
+
+var (
+	oidData                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidShroudedKeyBag      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509CertificateType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidLocalKeyID          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidSHA256              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data asn1.RawValue
+}
+
+// explicit wraps DER in an [0] EXPLICIT tag
+func explicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// dataContentInfo wraps DER in a pkcs #7 data content info
+func dataContentInfo(der []byte) (contentInfo, error) {
+	octets, err := asn1.Marshal(der)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{ContentType: oidData, Content: explicit(octets)}, nil
+}
+
+func newCertBag(der []byte, attributes []pkcs12Attribute) (safeBag, error) {
+	octets, err := asn1.Marshal(der)
+	if err != nil {
+		return safeBag{}, err
+	}
+	bag, err := asn1.Marshal(certBag{ID: oidX509CertificateType, Data: explicit(octets)})
+	if err != nil {
+		return safeBag{}, err
+	}
+	return safeBag{ID: oidCertBag, Value: explicit(bag), Attributes: attributes}, nil
+}
+
+// bmpString encodes a password as a null terminated big endian UTF-16 string as required by the pkcs #12 kdf
+func bmpString(s string) []byte {
+	out := []byte{}
+	for _, r := range utf16.Encode([]rune(s)) {
+		out = append(out, byte(r>>8), byte(r))
+	}
+	return append(out, 0, 0)
+}
+
+// pkcs12KDF derives key material using the pkcs #12 key derivation function (RFC 7292 appendix B.2)
+func pkcs12KDF(h func() hash.Hash, id byte, password, salt []byte, iterations, size int) []byte {
+	v := h().BlockSize()
+
+	fill := func(in []byte) []byte {
+		if len(in) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(in)+v-1)/v))
+		for i := range out {
+			out[i] = in[i%len(in)]
+		}
+		return out
+	}
+
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+	input := append(fill(salt), fill(password)...)
+
+	out := []byte{}
+	one := big.NewInt(1)
+	for len(out) < size {
+		digest := h()
+		digest.Write(d)
+		digest.Write(input)
+		a := digest.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			digest.Reset()
+			digest.Write(a)
+			a = digest.Sum(nil)
+		}
+		out = append(out, a...)
+
+		b := new(big.Int).SetBytes(fill(a)[:v])
+		b.Add(b, one)
+		for j := 0; j < len(input); j += v {
+			block := new(big.Int).SetBytes(input[j : j+v])
+			block.Add(block, b)
+			sum := block.Bytes()
+			if len(sum) > v {
+				sum = sum[len(sum)-v:]
+			}
+			copy(input[j:j+v], make([]byte, v))
+			copy(input[j+v-len(sum):j+v], sum)
+		}
+	}
+	return out[:size]
+}
+
+// pkcs12Mac computes the HMAC-SHA256 mac of the authenticated safe
+func pkcs12Mac(authSafe []byte, password string, salt []byte, iterations int) []byte {
+	key := pkcs12KDF(sha256.New, pkcs12MacKeyID, bmpString(password), salt, iterations, sha256.Size)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(authSafe)
+	return mac.Sum(nil)
+}
+
+// ExportPKCS12 bundles the pem encoded certificate, its private key and the pem encoded ca chain into a password
+// protected PKCS #12 (.p12) file, an empty password is accepted
+func ExportPKCS12(certPEM, keyPEM []byte, caPEM [][]byte, password string) ([]byte, error) {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	shrouded, err := encryptPKCS8(keyDER, []byte(password))
+	if err != nil {
+		return nil, err
+	}
+
+	// the local key id attribute links the private key to its certificate
+	localKeyID := sha1.Sum(cert.Raw)
+	localKeyIDOctets, err := asn1.Marshal(localKeyID[:])
+	if err != nil {
+		return nil, err
+	}
+	attributes := []pkcs12Attribute{{
+		ID:    oidLocalKeyID,
+		Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: localKeyIDOctets},
+	}}
+
+	certBags := []safeBag{}
+	bag, err := newCertBag(cert.Raw, attributes)
+	if err != nil {
+		return nil, err
+	}
+	certBags = append(certBags, bag)
+	for _, chainPEM := range caPEM {
+		chain, err := ParseChain(chainPEM)
+		if err != nil {
+			return nil, err
+		}
+		for _, ca := range chain {
+			bag, err := newCertBag(ca.Raw, nil)
+			if err != nil {
+				return nil, err
+			}
+			certBags = append(certBags, bag)
+		}
+	}
+
+	certContents, err := asn1.Marshal(certBags)
+	if err != nil {
+		return nil, err
+	}
+	keyContents, err := asn1.Marshal([]safeBag{{ID: oidShroudedKeyBag, Value: explicit(shrouded), Attributes: attributes}})
+	if err != nil {
+		return nil, err
+	}
+
+	safes := []contentInfo{}
+	for _, contents := range [][]byte{certContents, keyContents} {
+		ci, err := dataContentInfo(contents)
+		if err != nil {
+			return nil, err
+		}
+		safes = append(safes, ci)
+	}
+	authSafe, err := asn1.Marshal(safes)
+	if err != nil {
+		return nil, err
+	}
+	authSafeInfo, err := dataContentInfo(authSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, pkcs12MacSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pfxPdu{
+		Version:  3,
+		AuthSafe: authSafeInfo,
+		MacData: macData{
+			Mac: digestInfo{
+				Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+				Digest:    pkcs12Mac(authSafe, password, salt, pkcs12MacIterations),
+			},
+			MacSalt:    salt,
+			Iterations: pkcs12MacIterations,
+		},
+	})
+}
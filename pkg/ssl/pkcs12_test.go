@@ -0,0 +1,94 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPKCS12KDF(t *testing.T) {
+	salt, _ := hex.DecodeString("0A58CF64530D823F")
+	key := pkcs12KDF(sha1.New, 1, bmpString("smeg"), salt, 1, 24)
+	assert.Equal(t, "8aaae6297b6cb04642ab5b077851284eb7128f1a2a7fbca3", hex.EncodeToString(key))
+}
+
+func TestExportPKCS12(t *testing.T) {
+	ca, caKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	certPEM, keyPEM, err := Generate([]byte(testCSR), ca, caKey, time.Hour, UsagesForProfile(WebServer))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, password := range []string{"sécret", ""} {
+		p12, err := ExportPKCS12(certPEM, keyPEM, [][]byte{ca}, password)
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		var pfx pfxPdu
+		if _, err := asn1.Unmarshal(p12, &pfx); !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, 3, pfx.Version)
+		var authSafe []byte
+		_, err = asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe)
+		assert.NoError(t, err)
+		assert.Equal(t, pfx.MacData.Mac.Digest, pkcs12Mac(authSafe, password, pfx.MacData.MacSalt, pfx.MacData.Iterations))
+		assert.NotEqual(t, pfx.MacData.Mac.Digest, pkcs12Mac(authSafe, "wrong", pfx.MacData.MacSalt, pfx.MacData.Iterations))
+
+		var safes []contentInfo
+		_, err = asn1.Unmarshal(authSafe, &safes)
+		if !assert.NoError(t, err) || !assert.Len(t, safes, 2) {
+			continue
+		}
+		bags := [][]safeBag{}
+		for _, safe := range safes {
+			var contents []byte
+			_, err := asn1.Unmarshal(safe.Content.Bytes, &contents)
+			assert.NoError(t, err)
+			var b []safeBag
+			_, err = asn1.Unmarshal(contents, &b)
+			assert.NoError(t, err)
+			bags = append(bags, b)
+		}
+
+		assert.Len(t, bags[0], 2)
+		for _, bag := range bags[0] {
+			assert.Equal(t, oidCertBag, bag.ID)
+		}
+		if assert.Len(t, bags[1], 1) {
+			keyDER, err := decryptPKCS8(bags[1][0].Value.Bytes, []byte(password))
+			if assert.NoError(t, err) {
+				key, err := x509.ParsePKCS8PrivateKey(keyDER)
+				assert.NoError(t, err)
+				parsed, _ := ParseKey(keyPEM)
+				assert.Equal(t, parsed.PrivateKey(), key)
+			}
+		}
+	}
+
+	_, err = ExportPKCS12(certPEM, []byte("not a key"), nil, "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,197 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PKCS #8 encryption uses PBES2 with PBKDF2 (RFC 8018) as written by OpenSSL 3
+
+const (
+	pkcs8SaltSize   = 16
+	pkcs8Iterations = 100000
+	// pkcs8MaxIterations bounds the work a crafted key can demand when it is decrypted
+	pkcs8MaxIterations = 5000000
+)
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+type encryptedPrivateKeyInfo struct {
+	EncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+func pbkdf2Hash(prf asn1.ObjectIdentifier) (func() hash.Hash, error) {
+	switch {
+	case len(prf) == 0, prf.Equal(oidHMACWithSHA1):
+		return sha1.New, nil
+	case prf.Equal(oidHMACWithSHA256):
+		return sha256.New, nil
+	case prf.Equal(oidHMACWithSHA384):
+		return sha512.New384, nil
+	case prf.Equal(oidHMACWithSHA512):
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported pbkdf2 prf %s", prf)
+}
+
+func aesKeySize(scheme asn1.ObjectIdentifier) (int, error) {
+	switch {
+	case scheme.Equal(oidAES128CBC):
+		return 16, nil
+	case scheme.Equal(oidAES192CBC):
+		return 24, nil
+	case scheme.Equal(oidAES256CBC):
+		return 32, nil
+	}
+	return 0, fmt.Errorf("unsupported encryption scheme %s", scheme)
+}
+
+// encryptPKCS8 encrypts a DER encoded PKCS #8 private key using PBES2 with PBKDF2-HMAC-SHA256 and AES-256-CBC
+func encryptPKCS8(der, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, pkcs8SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	key := pbkdf2.Key(passphrase, salt, pkcs8Iterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	padding := aes.BlockSize - len(der)%aes.BlockSize
+	encrypted := append(append([]byte{}, der...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: pkcs8Iterations,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		EncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData:       encrypted,
+	})
+}
+
+// decryptPKCS8 decrypts a PBES2 encrypted PKCS #8 private key returning the DER encoded private key
+func decryptPKCS8(der, passphrase []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	if !info.EncryptionAlgorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported private key encryption %s", info.EncryptionAlgorithm.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.EncryptionAlgorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function %s", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdfParams pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, err
+	}
+	if kdfParams.IterationCount < 1 || kdfParams.IterationCount > pkcs8MaxIterations {
+		return nil, fmt.Errorf("unsupported key derivation iteration count %d", kdfParams.IterationCount)
+	}
+	prf, err := pbkdf2Hash(kdfParams.PRF.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	keySize, err := aesKeySize(params.EncryptionScheme.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize || len(info.EncryptedData) == 0 || len(info.EncryptedData)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid encrypted private key")
+	}
+
+	key := pbkdf2.Key(passphrase, kdfParams.Salt, kdfParams.IterationCount, keySize, prf)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, info.EncryptedData)
+
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(decrypted[len(decrypted)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errors.New("cannot decrypt private key - incorrect passphrase")
+	}
+	return decrypted[:len(decrypted)-padding], nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"math/big"
+	"time"
+)
+
+// maxSerialOctets is the maximum length of a serial number allowed by RFC 5280
+const maxSerialOctets = 20
+
+const (
+	// MinSerialBits is the smallest random serial number length, since the top bit is always set it gives the
+	// 64 bits of entropy required by the CA/Browser Forum
+	MinSerialBits = 65
+	// MaxSerialBits is the largest positive serial number length that fits in 20 octets
+	MaxSerialBits = maxSerialOctets*8 - 1
+	// DefaultSerialBits is the default random serial number length, the full 20 octets allowed by RFC 5280
+	DefaultSerialBits = MaxSerialBits
+)
+
+// randomSerial generates a random positive serial number of exactly the specified bit length, the top bit is
+// set so only the bits below it are random
+func randomSerial(bits int) (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), uint(bits-1)))
+	if err != nil {
+		return nil, err
+	}
+	return serial.SetBit(serial, bits-1, 1), nil
+}
+
+// DeterministicSerial derives a positive serial number of at most 20 octets by hashing the subject
+// and the start of the validity period, so the same inputs always produce the same serial
+func DeterministicSerial(subject pkix.Name, notBefore time.Time) *big.Int {
+	h := sha256.New()
+	if der, err := asn1.Marshal(subject.ToRDNSequence()); err == nil {
+		h.Write(der)
+	} else {
+		h.Write([]byte(subject.String()))
+	}
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(notBefore.UTC().Unix()))
+	h.Write(ts[:])
+
+	sum := h.Sum(nil)[:maxSerialOctets]
+	sum[0] &= 0x7f // clear the sign bit so the encoded serial fits in 20 octets
+	serial := new(big.Int).SetBytes(sum)
+	if serial.Sign() == 0 {
+		serial.SetInt64(1)
+	}
+	return serial
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeterministicSerial(t *testing.T) {
+	subject := pkix.Name{CommonName: "example.com", Organization: []string{"Example"}}
+	notBefore := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	serial := DeterministicSerial(subject, notBefore)
+	assert.Equal(t, serial, DeterministicSerial(subject, notBefore))
+	assert.Equal(t, 1, serial.Sign())
+	assert.LessOrEqual(t, len(serial.Bytes()), 20)
+	assert.Zero(t, serial.Bytes()[0]&0x80)
+
+	assert.NotEqual(t, serial, DeterministicSerial(subject, notBefore.Add(time.Second)))
+	assert.NotEqual(t, serial, DeterministicSerial(pkix.Name{CommonName: "other.example.com"}, notBefore))
+
+	caCert, _, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration, WithDeterministicSerial())
+	if assert.NoError(t, err) {
+		cert, err := parseCertificate(caCert)
+		if assert.NoError(t, err) {
+			assert.Equal(t, DeterministicSerial(cert.Subject, cert.NotBefore), cert.SerialNumber)
+		}
+	}
+}
+
+func TestSerialBits(t *testing.T) {
+	ca, _, err := GenerateCACertificate([]byte(testCSR), DefaultCertificateExpiration)
+	if assert.NoError(t, err) {
+		assert.GreaterOrEqual(t, ca.SerialNumber().BitLen(), DefaultSerialBits)
+	}
+
+	for _, bits := range []int{MinSerialBits, 96, MaxSerialBits} {
+		ca, _, err := GenerateCACertificate([]byte(testCSR), DefaultCertificateExpiration, WithSerialBits(bits))
+		if assert.NoError(t, err) {
+			assert.Equal(t, 1, ca.SerialNumber().Sign())
+			assert.GreaterOrEqual(t, ca.SerialNumber().BitLen(), bits)
+		}
+	}
+
+	_, _, err = GenerateCA([]byte(testCSR), DefaultCertificateExpiration, WithSerialBits(64))
+	assert.Error(t, err)
+	_, _, err = GenerateCA([]byte(testCSR), DefaultCertificateExpiration, WithSerialBits(MaxSerialBits+1))
+	assert.Error(t, err)
+}
+
+func TestRandomSerialEntropy(t *testing.T) {
+	for _, bits := range []int{MinSerialBits, 96, MaxSerialBits} {
+		// every random bit should take both values across the samples, the chance of one not doing so is 2^-63
+		ones, zeros := new(big.Int), new(big.Int)
+		mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(bits)), big.NewInt(1))
+		for i := 0; i < 64; i++ {
+			serial, err := randomSerial(bits)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, bits, serial.BitLen())
+			ones.Or(ones, serial)
+			zeros.Or(zeros, new(big.Int).AndNot(mask, serial))
+		}
+		varying := 0
+		for i := 0; i < bits; i++ {
+			if ones.Bit(i) == 1 && zeros.Bit(i) == 1 {
+				varying++
+			}
+		}
+		assert.Equal(t, bits-1, varying, "%d bit serials", bits)
+	}
+	assert.GreaterOrEqual(t, MinSerialBits-1, 64)
+}
+
+func TestSerialNumber(t *testing.T) {
+	serial := big.NewInt(424242)
+	caCert, caKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration, WithSerialNumber(serial), WithDeterministicSerial())
+	if !assert.NoError(t, err) {
+		return
+	}
+	cert, err := parseCertificate(caCert)
+	if assert.NoError(t, err) {
+		assert.Equal(t, serial, cert.SerialNumber)
+	}
+
+	certPEM, _, err := Generate([]byte(testCSR), caCert, caKey, time.Hour, UsagesForProfile(WebServer), WithSerialNumber(big.NewInt(7)))
+	if assert.NoError(t, err) {
+		cert, err := parseCertificate(certPEM)
+		if assert.NoError(t, err) {
+			assert.Equal(t, big.NewInt(7), cert.SerialNumber)
+		}
+	}
+
+	_, _, err = GenerateCA([]byte(testCSR), DefaultCertificateExpiration, WithSerialNumber(big.NewInt(0)))
+	assert.Error(t, err)
+	_, _, err = GenerateCA([]byte(testCSR), DefaultCertificateExpiration, WithSerialNumber(nil))
+	assert.Error(t, err)
+	_, _, err = GenerateCA([]byte(testCSR), DefaultCertificateExpiration, WithSerialNumber(new(big.Int).Lsh(big.NewInt(1), MaxSerialBits)))
+	assert.Error(t, err)
+}
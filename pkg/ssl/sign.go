@@ -0,0 +1,103 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+)
+
+func signatureHash(a x509.SignatureAlgorithm) (crypto.Hash, error) {
+	switch a {
+	case x509.SHA256WithRSA, x509.ECDSAWithSHA256:
+		return crypto.SHA256, nil
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384:
+		return crypto.SHA384, nil
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512:
+		return crypto.SHA512, nil
+	}
+	return 0, errors.New("unsupported signature algorithm")
+}
+
+// publicKeySignatureAlgorithm returns the signature algorithm a key with the public key would sign with
+func publicKeySignatureAlgorithm(pub crypto.PublicKey) (x509.SignatureAlgorithm, error) {
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		k := &rsaKey{baseKey{algorithm: RSAKey, size: p.Size() * 8}}
+		return k.SignatureAlgorithm(), nil
+	case *ecdsa.PublicKey:
+		k := &ecdsaKey{baseKey{algorithm: ECDSAKey, size: getCurveSize(p.Curve)}}
+		return k.SignatureAlgorithm(), nil
+	case ed25519.PublicKey:
+		return x509.PureEd25519, nil
+	}
+	return x509.UnknownSignatureAlgorithm, errors.New("unsupported public key type")
+}
+
+func digest(h crypto.Hash, data []byte) []byte {
+	hasher := h.New()
+	hasher.Write(data)
+	return hasher.Sum(nil)
+}
+
+// Sign signs data with the key using the hash of the key's signature algorithm. RSA keys produce
+// PKCS #1 v1.5 signatures, ECDSA keys ASN.1 encoded signatures and Ed25519 keys sign the data directly.
+func Sign(k Key, data []byte) ([]byte, error) {
+	signer, ok := k.PrivateKey().(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key cannot sign")
+	}
+	if k.SignatureAlgorithm() == x509.PureEd25519 {
+		return signer.Sign(rand.Reader, data, crypto.Hash(0))
+	}
+	h, err := signatureHash(k.SignatureAlgorithm())
+	if err != nil {
+		return nil, err
+	}
+	return signer.Sign(rand.Reader, digest(h, data), h)
+}
+
+// Verify verifies a signature over data produced by Sign with the private key of pub
+func Verify(pub crypto.PublicKey, data, sig []byte) error {
+	a, err := publicKeySignatureAlgorithm(pub)
+	if err != nil {
+		return err
+	}
+	if a == x509.PureEd25519 {
+		if !ed25519.Verify(pub.(ed25519.PublicKey), data, sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	h, err := signatureHash(a)
+	if err != nil {
+		return err
+	}
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(p, h, digest(h, data), sig)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(p, digest(h, data), sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return errors.New("unsupported public key type")
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignVerify(t *testing.T) {
+	var testValues = []struct {
+		algorithm KeyAlgorithm
+		size      int
+	}{
+		{ECDSAKey, 256},
+		{ECDSAKey, 384},
+		{ECDSAKey, 521},
+		{RSAKey, 2048},
+		{Ed25519Key, 0},
+	}
+
+	data := []byte("package manifest")
+	for _, tv := range testValues {
+		key, err := GenerateKey(tv.algorithm, tv.size)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		sig, err := Sign(key, data)
+		if assert.NoError(t, err) {
+			assert.NoError(t, Verify(key.PublicKey(), data, sig), "%s %d", tv.algorithm, tv.size)
+			assert.Error(t, Verify(key.PublicKey(), []byte("tampered manifest"), sig), "%s %d", tv.algorithm, tv.size)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrCertificateExpired is returned when a certificate is expired or not yet valid
+	ErrCertificateExpired = errors.New("certificate is expired or not yet valid")
+	// ErrIncompatibleUsage is returned when a certificate is not valid for the requested usages
+	ErrIncompatibleUsage = errors.New("certificate is not valid for the requested usage")
+	// ErrHostnameMismatch is returned when a certificate is not valid for the requested host
+	ErrHostnameMismatch = errors.New("certificate is not valid for the requested host")
+	// ErrUnknownAuthority is returned when a certificate is not signed by the certificate authority
+	ErrUnknownAuthority = errors.New("certificate is signed by an unknown authority")
+)
+
+// VerifyOptions specifies how a certificate is verified
+type VerifyOptions struct {
+	DNSName     string    // DNSName optionally checks the certificate is valid for the host
+	CurrentTime time.Time // CurrentTime is the time to check the validity period at, the current time if zero
+	Usages      []string  // Usages optionally requires the certificate to have the extended key usages
+}
+
+// VerifyCertificate checks that the pem encoded leaf certificate chains up to the pem encoded certificate
+// authority, errors wrap ErrCertificateExpired, ErrIncompatibleUsage, ErrHostnameMismatch or ErrUnknownAuthority
+func VerifyCertificate(leafPEM, caPEM []byte, opts VerifyOptions) error {
+	leaf, err := parseCertificate(leafPEM)
+	if err != nil {
+		return err
+	}
+	cas, err := ParseChain(caPEM)
+	if err != nil {
+		return err
+	}
+
+	roots := x509.NewCertPool()
+	for _, ca := range cas {
+		roots.AddCert(ca)
+	}
+
+	usages := []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	if len(opts.Usages) > 0 {
+		_, usages = sortUsages(opts.Usages)
+		if len(usages) == 0 {
+			return errors.New("no extended key usage(s) specified")
+		}
+	}
+
+	_, err = leaf.Verify(x509.VerifyOptions{
+		DNSName:     opts.DNSName,
+		CurrentTime: opts.CurrentTime,
+		Roots:       roots,
+		KeyUsages:   usages,
+	})
+	return verificationError(err)
+}
+
+func verificationError(err error) error {
+	var invalid x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	var unknown x509.UnknownAuthorityError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return fmt.Errorf("%w: %v", ErrCertificateExpired, err)
+	case errors.As(err, &invalid) && invalid.Reason == x509.IncompatibleUsage:
+		return fmt.Errorf("%w: %v", ErrIncompatibleUsage, err)
+	case errors.As(err, &hostname):
+		return fmt.Errorf("%w: %v", ErrHostnameMismatch, err)
+	case errors.As(err, &unknown):
+		return fmt.Errorf("%w: %v", ErrUnknownAuthority, err)
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 Limejuice-cc Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyCertificate(t *testing.T) {
+	ca, caKey, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+	leaf, _, err := Generate([]byte(testCSR), ca, caKey, time.Hour, UsagesForProfile(WebServer))
+	if !assert.NoError(t, err) {
+		return
+	}
+	other, _, err := GenerateCA([]byte(testCSR), DefaultCertificateExpiration)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, VerifyCertificate(leaf, ca, VerifyOptions{}))
+	assert.NoError(t, VerifyCertificate(leaf, ca, VerifyOptions{DNSName: "example.com", Usages: []string{"server auth"}}))
+
+	err = VerifyCertificate(leaf, ca, VerifyOptions{CurrentTime: time.Now().Add(2 * time.Hour)})
+	assert.ErrorIs(t, err, ErrCertificateExpired)
+	err = VerifyCertificate(leaf, ca, VerifyOptions{CurrentTime: time.Now().Add(-time.Hour)})
+	assert.ErrorIs(t, err, ErrCertificateExpired)
+	err = VerifyCertificate(leaf, ca, VerifyOptions{Usages: []string{"client auth"}})
+	assert.ErrorIs(t, err, ErrIncompatibleUsage)
+	err = VerifyCertificate(leaf, ca, VerifyOptions{DNSName: "other.example.org"})
+	assert.ErrorIs(t, err, ErrHostnameMismatch)
+	err = VerifyCertificate(leaf, other, VerifyOptions{})
+	assert.ErrorIs(t, err, ErrUnknownAuthority)
+
+	assert.Error(t, VerifyCertificate(leaf, []byte("not a certificate"), VerifyOptions{}))
+	assert.Error(t, VerifyCertificate(leaf, ca, VerifyOptions{Usages: []string{"cert sign"}}))
+}
@@ -52,6 +52,38 @@ func RemoveOuterQuotes(kv *Pair) error {
 	return nil
 }
 
+// UnescapeC replaces the escape sequences \n, \r, \t and \\ in a value with the characters they
+// represent, leaving any other backslashes untouched
+func UnescapeC(kv *Pair) error {
+	if !strings.Contains(kv.Value, "\\") {
+		return nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(kv.Value); i++ {
+		c := kv.Value[i]
+		if c != '\\' || i+1 >= len(kv.Value) {
+			sb.WriteByte(c)
+			continue
+		}
+		switch kv.Value[i+1] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case '\\':
+			sb.WriteByte('\\')
+		default:
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+	}
+	kv.Value = sb.String()
+	return nil
+}
+
 // KeyToUpper transforms the key to uppercase
 func KeyToUpper(kv *Pair) error {
 	kv.Key = strings.ToUpper(kv.Key)
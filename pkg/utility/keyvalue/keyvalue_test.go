@@ -75,3 +75,23 @@ ID=test
 		}
 	}
 }
+
+func TestUnescapeC(t *testing.T) {
+	var testValues = []struct {
+		value    string
+		expected string
+	}{
+		{`KEY=line1\nline2`, "line1\nline2"},
+		{`KEY="col1\tcol2\r\n"`, "col1\tcol2\r\n"},
+		{`KEY=C:\\temp`, `C:\temp`},
+		{`KEY=unknown\q escape\`, `unknown\q escape\`},
+		{`KEY=plain`, "plain"},
+	}
+
+	for _, tv := range testValues {
+		kv, err := ParsePair(tv.value, RemoveOuterQuotes, UnescapeC)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tv.expected, kv.Value)
+		}
+	}
+}